@@ -2,13 +2,19 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/signal"
 	"runtime"
 	"runtime/debug"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/PavelAgarkov/service-pkg/logger"
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
@@ -24,6 +30,22 @@ const (
 	HighestPriority   = 50
 	CriticalPriority  = 20
 	ImmediatePriority = 1
+
+	// LoggerFlushPriority зарезервированная фаза для сброса логгера и похожих хуков: они выполняются самыми последними,
+	// после всех приоритетов и стека RegisterShutdownLIFO, чтобы логи остальных хуков не потерялись.
+	LoggerFlushPriority = math.MaxInt
+)
+
+const (
+	reElectMinBackoff = 1 * time.Second
+	reElectMaxBackoff = 30 * time.Second
+
+	supervisorCheckInterval = 50 * time.Millisecond
+
+	// DefaultDrainTimeout общий дедлайн на все Drainable в App.Stop
+	DefaultDrainTimeout = 30 * time.Second
+	// DefaultShutdownHookTimeout сколько App.Stop ждёт один shutdown-хук, если таймаут не задан явно
+	DefaultShutdownHookTimeout = 10 * time.Second
 )
 
 type linkedList struct {
@@ -34,7 +56,14 @@ type shutdown struct {
 	priority     int
 	name         string
 	next         *shutdown
-	shutdownFunc func()
+	shutdownFunc func(ctx context.Context) error
+	timeout      time.Duration
+}
+
+type drainable struct {
+	name     string
+	priority int
+	d        Drainable
 }
 
 type LeaderSupervisor struct {
@@ -47,20 +76,78 @@ type LeaderSupervisor struct {
 	SupervisorName string
 	mu             sync.Mutex
 	Working        bool
+	exited         atomic.Bool // горутина-потребитель Watcher завершилась
+
+	// ElectionConfig нужен для повторного Elect, если канал Watcher закрылся (горутина watchdog умерла).
+	// Если ElectionName пустой, повторных выборов не будет.
+	ElectionConfig watchdog.Config
 }
 
 type App struct {
 	ctx               context.Context
 	shutdownRWM       sync.RWMutex
 	shutdown          *linkedList
+	lifo              *linkedList
+	shutdownObserver  func(name string)
+	warnShutdownOrder bool
+	repanicShutdown   bool
+	parallelShutdown  bool
+	drainMu           sync.Mutex
+	drainables        []drainable
 	leaderSupervisors []*LeaderSupervisor
+	supervisorsWG     sync.WaitGroup // горутины-потребители Watcher, Stop ждёт их выхода
 	sig               chan os.Signal
+	sigStop           chan struct{}
+	sigStopOnce       sync.Once
+	reloadSig         chan os.Signal
+	signals           []os.Signal
+	forceQuitAfter    int
+	cpuQuota          CPUQuota
+	shuttingDown      atomic.Bool
+	reloadMu          sync.Mutex
+	reloaders         []reloader
+}
+
+type AppOption func(*App)
+
+// WithShutdownSignals задаёт сигналы, по которым App.Start запускает остановку, вместо SIGTERM/SIGINT/SIGQUIT.
+// Например, только SIGTERM в продакшене или дополнительно SIGUSR2. SIGHUP сюда не передавать — он занят под OnReload.
+func WithShutdownSignals(signals ...os.Signal) AppOption {
+	return func(app *App) {
+		// пустой список signal.Notify трактует как «все сигналы» — оставляем набор по умолчанию
+		if len(signals) > 0 {
+			app.signals = signals
+		}
+	}
+}
+
+// WithForceQuitAfter если за время остановки пришло n сигналов (считая первый), процесс завершается
+// сразу с кодом 1, не дожидаясь shutdown-хуков. Для «зависшей» остановки, которую оператор добивает повторным Ctrl+C.
+// n <= 1 — выключено, повторные сигналы только логируются.
+func WithForceQuitAfter(n int) AppOption {
+	return func(app *App) {
+		app.forceQuitAfter = n
+	}
 }
 
-func NewApp(ctx context.Context, cores int, heapOverflow int) *App {
+func NewApp(ctx context.Context, cores int, heapOverflow int, opts ...AppOption) *App {
+	app := &App{
+		shutdown:  &linkedList{},
+		lifo:      &linkedList{},
+		ctx:       ctx,
+		sig:       make(chan os.Signal, 1),
+		sigStop:   make(chan struct{}),
+		reloadSig: make(chan os.Signal, 1),
+		signals:   []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT},
+	}
+	for _, opt := range opts {
+		opt(app)
+	}
+
 	if heapOverflow == 0 {
 		heapOverflow = 100
 	}
+	cores = maxProcs(ctx, cores, app.cpuQuota)
 	debug.SetGCPercent(heapOverflow)
 	runtime.GOMAXPROCS(cores)
 	logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
@@ -69,11 +156,7 @@ func NewApp(ctx context.Context, cores int, heapOverflow int) *App {
 		Method:    "NewApp",
 		Args:      fmt.Sprintf("cores: %d, heapOverflow: %d", cores, heapOverflow),
 	})
-	return &App{
-		shutdown: &linkedList{},
-		ctx:      ctx,
-		sig:      make(chan os.Signal, 1),
-	}
+	return app
 }
 
 func (app *App) StartWatchdogsLeadership() {
@@ -87,10 +170,15 @@ func (app *App) StartWatchdogsLeadership() {
 	}
 
 	for _, supervisor := range app.leaderSupervisors {
-		utils.GoRecover(app.ctx, func(ctx context.Context) {
+		app.supervisorsWG.Add(1)
+		// WithoutCancel: горутина должна стартовать всегда, иначе Done не будет вызван и Stop зависнет
+		utils.GoRecover(context.WithoutCancel(app.ctx), func(context.Context) {
+			defer app.supervisorsWG.Done()
+			defer supervisor.exited.Store(true)
+			backoff := reElectMinBackoff
 			for {
 				select {
-				case <-ctx.Done():
+				case <-app.ctx.Done():
 					logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
 						Msg:       fmt.Sprintf("Stopping supervisor %s due to context cancellation", supervisor.SupervisorName),
 						Component: "application",
@@ -109,8 +197,26 @@ func (app *App) StartWatchdogsLeadership() {
 							Component: "application",
 							Method:    "StartWatchdogsLeadership",
 						})
+						if !app.reElect(supervisor, &backoff) {
+							return
+						}
+						continue
+					}
+					if res == watchdog.GaveUp {
+						supervisor.mu.Lock()
+						if supervisor.Working {
+							supervisor.Stop()
+							supervisor.Working = false
+						}
+						supervisor.mu.Unlock()
+						logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+							Msg:       fmt.Sprintf("Supervisor %s gave up leadership after repeated lock failures", supervisor.SupervisorName),
+							Component: "application",
+							Method:    "StartWatchdogsLeadership",
+						})
 						return
 					}
+					backoff = reElectMinBackoff
 					if res == watchdog.LostAcquire {
 						supervisor.mu.Lock()
 						if supervisor.Working {
@@ -143,6 +249,93 @@ func (app *App) StartWatchdogsLeadership() {
 	}
 }
 
+// StartWatchdogsLeadershipChecked запускает супервизоры и в течение timeout проверяет, что каждый в рабочем состоянии:
+// лидер (Working) или ведомый, чья горутина-потребитель жива и ждёт лидерства. Если потребитель завершился
+// (watchdog сдался с GaveUp, Start супервизора запаниковал, повторные выборы невозможны), возвращает ошибку сразу.
+// Если все супервизоры стали лидерами раньше timeout, возвращается сразу; иначе ждёт timeout целиком.
+func (app *App) StartWatchdogsLeadershipChecked(timeout time.Duration) error {
+	app.StartWatchdogsLeadership()
+
+	ticker := time.NewTicker(supervisorCheckInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		var failed []string
+		leaders := 0
+		for _, supervisor := range app.leaderSupervisors {
+			if supervisor.exited.Load() {
+				failed = append(failed, supervisor.SupervisorName)
+				continue
+			}
+			supervisor.mu.Lock()
+			if supervisor.Working {
+				leaders++
+			}
+			supervisor.mu.Unlock()
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("supervisors failed to start: %v", failed)
+		}
+		// все уже лидеры и работают — ждать таймаут незачем
+		if leaders == len(app.leaderSupervisors) {
+			return nil
+		}
+
+		select {
+		case <-app.ctx.Done():
+			return app.ctx.Err()
+		case <-deadline.C:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// MustStart как StartWatchdogsLeadershipChecked, но паникует при ошибке: для сервисов, бесполезных без супервизоров.
+func (app *App) MustStart(timeout time.Duration) {
+	if err := app.StartWatchdogsLeadershipChecked(timeout); err != nil {
+		logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+			Msg:       "Critical supervisors failed to start",
+			Component: "application",
+			Method:    "MustStart",
+			Error:     err,
+		})
+		panic(err)
+	}
+}
+
+// reElect вызывается, когда канал Watcher закрылся: лидерство считаем потерянным,
+// останавливаем подсистему и после паузы запрашиваем у Watchdog новый канал.
+// Возвращает false, если повторные выборы невозможны или супервизор остановлен.
+func (app *App) reElect(supervisor *LeaderSupervisor, backoff *time.Duration) bool {
+	supervisor.mu.Lock()
+	if supervisor.Working {
+		supervisor.Stop()
+		supervisor.Working = false
+	}
+	supervisor.mu.Unlock()
+
+	if supervisor.Watchdog == nil || supervisor.ElectionConfig.ElectionName == "" {
+		return false
+	}
+
+	logger.WriteWarnLog(app.ctx, &logger_wrapper.LogEntry{
+		Msg:       fmt.Sprintf("Supervisor %s will re-elect after %s", supervisor.SupervisorName, *backoff),
+		Component: "application",
+		Method:    "reElect",
+		Args:      supervisor.ElectionConfig.ElectionName,
+	})
+	if err := utils.WaitOrCtx(supervisor.ctx, *backoff); err != nil {
+		return false
+	}
+	*backoff = min(*backoff*2, reElectMaxBackoff)
+
+	supervisor.Watcher = supervisor.Watchdog.Elect(supervisor.ElectionConfig)
+	return true
+}
+
 func (app *App) RegisterWatchdogsLeadership(supervisor *LeaderSupervisor) {
 	if supervisor == nil {
 		logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
@@ -158,7 +351,31 @@ func (app *App) RegisterWatchdogsLeadership(supervisor *LeaderSupervisor) {
 	app.leaderSupervisors = append(app.leaderSupervisors, supervisor)
 }
 
+// RegisterShutdown регистрирует хук остановки. Меньшее число priority — выполняется раньше.
+// Хуки с одинаковым приоритетом выполняются в порядке регистрации (FIFO) — это гарантия, на неё можно опираться
+// (кроме режима ParallelShutdownTiers).
+// Приоритет LoggerFlushPriority выполняется после всех остальных хуков, включая LIFO.
 func (app *App) RegisterShutdown(name string, fn func(), priority int) {
+	app.registerShutdown(name, func(context.Context) error {
+		fn()
+		return nil
+	}, priority, DefaultShutdownHookTimeout)
+}
+
+// RegisterShutdownWithTimeout регистрирует хук со своим таймаутом вместо DefaultShutdownHookTimeout.
+// По истечении таймаута контекст fn отменяется, App.Stop пишет warning и переходит к следующему хуку,
+// не дожидаясь fn. timeout <= 0 — DefaultShutdownHookTimeout.
+func (app *App) RegisterShutdownWithTimeout(name string, fn func(context.Context), priority int, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultShutdownHookTimeout
+	}
+	app.registerShutdown(name, func(ctx context.Context) error {
+		fn(ctx)
+		return nil
+	}, priority, timeout)
+}
+
+func (app *App) registerShutdown(name string, fn func(context.Context) error, priority int, timeout time.Duration) {
 	defer func() {
 		logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
 			Msg:       fmt.Sprintf("Registered shutdown func %s with priority %d", name, priority),
@@ -166,12 +383,21 @@ func (app *App) RegisterShutdown(name string, fn func(), priority int) {
 			Method:    "RegisterShutdown",
 		})
 	}()
+	if app.warnShutdownOrder && priority != LoggerFlushPriority && looksLikeLoggerFlush(name) {
+		logger.WriteWarnLog(app.ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("Shutdown func %s looks like a logger flush, register it with LoggerFlushPriority to run last", name),
+			Component: "application",
+			Method:    "RegisterShutdown",
+			Args:      priority,
+		})
+	}
 	app.shutdownRWM.Lock()
 	defer app.shutdownRWM.Unlock()
 	newShutdown := &shutdown{
 		name:         name,
 		priority:     priority,
 		shutdownFunc: fn,
+		timeout:      timeout,
 	}
 	if app.shutdown.node == nil || app.shutdown.node.priority > priority {
 		newShutdown.next = app.shutdown.node
@@ -179,6 +405,7 @@ func (app *App) RegisterShutdown(name string, fn func(), priority int) {
 		return
 	}
 	current := app.shutdown.node
+	// <= а не <: равный приоритет встаёт после уже зарегистрированных, отсюда FIFO внутри приоритета
 	for current.next != nil && current.next.priority <= priority {
 		current = current.next
 	}
@@ -186,21 +413,302 @@ func (app *App) RegisterShutdown(name string, fn func(), priority int) {
 	current.next = newShutdown
 }
 
-func (app *App) shutdownAllAndDeleteAllCanceled() {
+// RegisterShutdownE регистрирует хук с ошибкой (закрытие БД, Redis и т.п.): fn получает контекст
+// с DefaultShutdownHookTimeout, ошибка логируется и попадает в общую ошибку App.Stop.
+func (app *App) RegisterShutdownE(name string, fn func(context.Context) error, priority int) {
+	app.registerShutdown(name, fn, priority, DefaultShutdownHookTimeout)
+}
+
+// RegisterShutdownFunc то же, что RegisterShutdownE; оставлен для совместимости.
+func (app *App) RegisterShutdownFunc(name string, fn func(context.Context) error, priority int) {
+	app.RegisterShutdownE(name, fn, priority)
+}
+
+// DeregisterShutdown убирает хук name, например чтобы заменить его после пересоздания ресурса.
+// Имена не обязаны быть уникальными: удаляется первое совпадение, сначала среди хуков RegisterShutdown*,
+// затем в стеке LIFO. Возвращает, был ли хук найден.
+func (app *App) DeregisterShutdown(name string) bool {
 	app.shutdownRWM.Lock()
 	defer app.shutdownRWM.Unlock()
-	for app.shutdown.node != nil {
-		app.shutdown.node.shutdownFunc()
+
+	for _, list := range []*linkedList{app.shutdown, app.lifo} {
+		for link := &list.node; *link != nil; link = &(*link).next {
+			if (*link).name == name {
+				*link = (*link).next
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OnShutdownHook задаёт наблюдателя, который вызывается после каждого выполненного shutdown-хука
+// (включая LIFO) с его именем. Позволяет в тестах сервиса проверить порядок остановки, например «HTTP раньше БД».
+func (app *App) OnShutdownHook(observer func(name string)) {
+	app.shutdownRWM.Lock()
+	app.shutdownObserver = observer
+	app.shutdownRWM.Unlock()
+}
+
+// WarnShutdownOrder включает эвристическую проверку при RegisterShutdown: хук, похожий по имени на сброс логгера
+// (содержит "logger" или "flush"), зарегистрированный не с LoggerFlushPriority, даёт warning.
+func (app *App) WarnShutdownOrder(enabled bool) {
+	app.shutdownRWM.Lock()
+	defer app.shutdownRWM.Unlock()
+	app.warnShutdownOrder = enabled
+}
+
+// RepanicOnShutdownPanic паника в shutdown-хуке всегда ловится, логируется, и остальные хуки выполняются.
+// С enabled == true App.Stop после выполнения всех хуков паникует заново значением первой пойманной паники,
+// чтобы процесс упал так же, как без recover.
+func (app *App) RepanicOnShutdownPanic(enabled bool) {
+	app.shutdownRWM.Lock()
+	defer app.shutdownRWM.Unlock()
+	app.repanicShutdown = enabled
+}
+
+// ParallelShutdownTiers с enabled == true хуки RegisterShutdown с одинаковым приоритетом выполняются
+// одновременно, а следующий приоритет начинается, когда закончат все хуки текущего. Порядок между
+// приоритетами сохраняется, а FIFO внутри приоритета — нет, поэтому включать, только если хуки
+// одного приоритета независимы. LIFO и LoggerFlushPriority всегда выполняются последовательно.
+func (app *App) ParallelShutdownTiers(enabled bool) {
+	app.shutdownRWM.Lock()
+	defer app.shutdownRWM.Unlock()
+	app.parallelShutdown = enabled
+}
+
+func looksLikeLoggerFlush(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "logger") || strings.Contains(name, "flush")
+}
+
+// RegisterShutdownLIFO регистрирует хук в отдельный стек, который выполняется после всех хуков RegisterShutdown
+// в порядке, обратном регистрации: последним открыт — первым закрыт. Подходит для вложенных ресурсов
+// (открыли БД, потом кэш prepared statements поверх неё — закроется сначала кэш, потом БД).
+func (app *App) RegisterShutdownLIFO(name string, fn func()) {
+	app.shutdownRWM.Lock()
+	app.lifo.node = &shutdown{
+		name: name,
+		shutdownFunc: func(context.Context) error {
+			fn()
+			return nil
+		},
+		timeout: DefaultShutdownHookTimeout,
+		next:    app.lifo.node,
+	}
+	app.shutdownRWM.Unlock()
+
+	logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
+		Msg:       fmt.Sprintf("Registered LIFO shutdown func %s", name),
+		Component: "application",
+		Method:    "RegisterShutdownLIFO",
+	})
+}
+
+// RegisterDrainable регистрирует компонент, который App.Stop дренирует до запуска shutdown-хуков.
+// Порядок как у RegisterShutdown: меньшее число — раньше, при равенстве — в порядке регистрации.
+func (app *App) RegisterDrainable(name string, d Drainable, priority int) {
+	app.drainMu.Lock()
+	defer app.drainMu.Unlock()
+
+	i := len(app.drainables)
+	for i > 0 && app.drainables[i-1].priority > priority {
+		i--
+	}
+	app.drainables = slices.Insert(app.drainables, i, drainable{name: name, priority: priority, d: d})
+
+	logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
+		Msg:       fmt.Sprintf("Registered drainable %s with priority %d", name, priority),
+		Component: "application",
+		Method:    "RegisterDrainable",
+	})
+}
+
+// DrainAll последовательно дренирует все зарегистрированные компоненты под общим дедлайном ctx.
+// Ошибка одного компонента не прерывает остальные, все ошибки возвращаются вместе.
+func (app *App) DrainAll(ctx context.Context) error {
+	app.drainMu.Lock()
+	drainables := slices.Clone(app.drainables)
+	app.drainMu.Unlock()
+
+	var errs []error
+	for _, d := range drainables {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("drain %s: %w", d.name, err))
+			continue
+		}
+		start := time.Now()
+		if err := d.d.Drain(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("drain %s: %w", d.name, err))
+			logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Drain %s failed", d.name),
+				Component: "application",
+				Method:    "DrainAll",
+				Error:     err,
+				Start:     &start,
+			})
+			continue
+		}
 		logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
-			Msg:       fmt.Sprintf("Shutdown func %s executed with priority %d", app.shutdown.node.name, app.shutdown.node.priority),
+			Msg:       fmt.Sprintf("Drained %s with priority %d", d.name, d.priority),
 			Component: "application",
-			Method:    "shutdownAllAndDeleteAllCanceled",
+			Method:    "DrainAll",
+			Start:     &start,
 		})
+	}
+	return errors.Join(errs...)
+}
+
+// shutdownAllAndDeleteAllCanceled выполняет все хуки; ошибка одного не прерывает остальные, все ошибки возвращаются вместе.
+func (app *App) shutdownAllAndDeleteAllCanceled() error {
+	app.shutdownRWM.Lock()
+	defer app.shutdownRWM.Unlock()
+
+	var errs []error
+	// хуки LoggerFlushPriority стоят в конце списка и ждут, пока отработает LIFO
+	for app.shutdown.node != nil && app.shutdown.node.priority != LoggerFlushPriority {
+		tier := []*shutdown{app.shutdown.node}
+		if app.parallelShutdown {
+			for n := app.shutdown.node.next; n != nil && n.priority == tier[0].priority; n = n.next {
+				tier = append(tier, n)
+			}
+		}
+		tierErrs := make([]error, len(tier))
+		if len(tier) == 1 {
+			tierErrs[0] = app.runShutdown(tier[0])
+		} else {
+			var wg sync.WaitGroup
+			for i, node := range tier {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					tierErrs[i] = app.runShutdown(node)
+				}()
+			}
+			wg.Wait()
+		}
+
+		// наблюдатель вызывается уже после всего яруса и в порядке регистрации, а не завершения
+		for i, node := range tier {
+			app.observeShutdown(node.name)
+			if tierErrs[i] != nil {
+				errs = append(errs, tierErrs[i])
+				continue
+			}
+			logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Shutdown func %s executed with priority %d", node.name, node.priority),
+				Component: "application",
+				Method:    "shutdownAllAndDeleteAllCanceled",
+			})
+		}
+		app.shutdown.node = tier[len(tier)-1].next
+	}
+	for app.lifo.node != nil {
+		err := app.runShutdown(app.lifo.node)
+		app.observeShutdown(app.lifo.node.name)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("LIFO shutdown func %s executed", app.lifo.node.name),
+				Component: "application",
+				Method:    "shutdownAllAndDeleteAllCanceled",
+			})
+		}
+		app.lifo.node = app.lifo.node.next
+	}
+	// без лога о выполнении: здесь логгер уже сбрасывается
+	for app.shutdown.node != nil {
+		if err := app.shutdown.node.run(); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown %s: %w", app.shutdown.node.name, err))
+		}
+		app.observeShutdown(app.shutdown.node.name)
 		app.shutdown.node = app.shutdown.node.next
 	}
+	return errors.Join(errs...)
+}
+
+// runShutdown выполняет хук и логирует его сбой. Может вызываться конкурентно для хуков одного яруса.
+func (app *App) runShutdown(node *shutdown) error {
+	err := node.run()
+	if err == nil {
+		return nil
+	}
+	var hp *hookPanic
+	if errors.As(err, &hp) {
+		logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("Shutdown func %s panicked", node.name),
+			Component: "application",
+			Method:    "shutdownAllAndDeleteAllCanceled",
+			Args:      string(hp.stack),
+			Error:     err,
+		})
+		return fmt.Errorf("shutdown %s: %w", node.name, err)
+	}
+	if errors.Is(err, errShutdownTimeout) {
+		logger.WriteWarnLog(app.ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("Shutdown func %s did not finish in %s, moving on", node.name, node.timeout),
+			Component: "application",
+			Method:    "shutdownAllAndDeleteAllCanceled",
+		})
+		return fmt.Errorf("shutdown %s: %w", node.name, err)
+	}
+	logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+		Msg:       fmt.Sprintf("Shutdown func %s failed", node.name),
+		Component: "application",
+		Method:    "shutdownAllAndDeleteAllCanceled",
+		Error:     err,
+	})
+	return fmt.Errorf("shutdown %s: %w", node.name, err)
+}
+
+var errShutdownTimeout = errors.New("shutdown hook timed out")
+
+// hookPanic паника shutdown-хука, пойманная в run
+type hookPanic struct {
+	value any
+	stack []byte
+}
+
+func (p *hookPanic) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
+
+// run выполняет хук в отдельной горутине и ждёт его не дольше timeout: зависший хук не должен блокировать
+// остальные. Паника хука возвращается ошибкой.
+func (node *shutdown) run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), node.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- &hookPanic{value: r, stack: debug.Stack()}
+			}
+		}()
+		done <- node.shutdownFunc(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errShutdownTimeout
+	}
 }
 
-func (app *App) Stop() {
+// observeShutdown вызывать под shutdownRWM
+func (app *App) observeShutdown(name string) {
+	if app.shutdownObserver != nil {
+		app.shutdownObserver(name)
+	}
+}
+
+// Stop останавливает супервизоры, дренирует компоненты и выполняет shutdown-хуки.
+// Возвращает все ошибки дренажа и хуков вместе (errors.Join), чтобы main мог выставить код выхода.
+func (app *App) Stop() error {
+	app.shuttingDown.Store(true)
 	for _, supervisor := range app.leaderSupervisors {
 		supervisor.mu.Lock()
 		supervisor.cancel()
@@ -215,43 +723,149 @@ func (app *App) Stop() {
 			Method:    "Stop",
 		})
 	}
+	// потребители Watcher могли быть посреди Start/Stop супервизора — shutdown-хуки запускаем только после их выхода
+	app.supervisorsWG.Wait()
+
 	logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
 		Msg:       "Stopping application",
 		Component: "application",
 		Method:    "Stop",
 	})
-	app.shutdownAllAndDeleteAllCanceled()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), DefaultDrainTimeout)
+	drainErr := app.DrainAll(drainCtx)
+	cancel()
+
+	shutdownErr := app.shutdownAllAndDeleteAllCanceled()
+	app.stopSignals()
+
+	app.shutdownRWM.RLock()
+	repanic := app.repanicShutdown
+	app.shutdownRWM.RUnlock()
+	var hp *hookPanic
+	if repanic && errors.As(shutdownErr, &hp) {
+		panic(hp.value)
+	}
+	return errors.Join(drainErr, shutdownErr)
 }
 
+// Start подписывается на SIGTERM/SIGINT/SIGQUIT (или сигналы из WithShutdownSignals) и держит цикл обработки сигналов до конца App.Stop.
+// Первый сигнал вызывает cancel(), повторные тоже обрабатываются (cancel идемпотентен),
+// поэтому сигнал, пришедший уже после первого (например из RegisterRecovers), не теряется.
+// Каждый сигнал логируется с типом: первый — как начало остановки, повторные — warning «already shutting down»
+// (или принудительный выход, см. WithForceQuitAfter).
+// SIGHUP не останавливает приложение, а запускает хуки OnReload.
+// После App.Stop подписка снимается и сигналы снова обрабатываются рантаймом по умолчанию.
 func (app *App) Start(cancel context.CancelFunc) {
-	signal.Notify(app.sig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	signal.Notify(app.sig, app.signals...)
+	signal.Notify(app.reloadSig, syscall.SIGHUP)
 
-	utils.GoRecover(app.ctx, func(ctx context.Context) {
+	// цикл не должен зависеть от app.ctx — cancel() как раз его и отменяет
+	utils.GoRecover(context.WithoutCancel(app.ctx), func(ctx context.Context) {
 		defer signal.Stop(app.sig)
-		<-app.sig
+		defer signal.Stop(app.reloadSig)
+		received := 0
+		for {
+			select {
+			case <-app.sigStop:
+				return
+			case <-app.reloadSig:
+				_ = app.Reload()
+			case s := <-app.sig:
+				received++
+				app.logSignal(s, received)
+				cancel()
+			}
+		}
+	})
+}
+
+// logSignal первый сигнал начинает остановку, повторные только логируются как пришедшие во время остановки,
+// либо, при WithForceQuitAfter, завершают процесс.
+func (app *App) logSignal(s os.Signal, received int) {
+	if received == 1 {
 		logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
 			Msg:       "Signal received. Shutting down application...",
 			Component: "application",
 			Method:    "Start",
+			Args:      s.String(),
 		})
-		cancel()
+		return
+	}
+	if app.forceQuitAfter > 1 && received >= app.forceQuitAfter {
+		logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("Signal %s received %d times during shutdown, force quitting", s, received),
+			Component: "application",
+			Method:    "Start",
+			Args:      s.String(),
+		})
+		logger.FlushLogs()
+		os.Exit(1)
+	}
+	logger.WriteWarnLog(app.ctx, &logger_wrapper.LogEntry{
+		Msg:       fmt.Sprintf("Signal %s received while already shutting down (%d so far)", s, received),
+		Component: "application",
+		Method:    "Start",
+		Args:      s.String(),
+	})
+}
+
+func (app *App) stopSignals() {
+	app.sigStopOnce.Do(func() {
+		close(app.sigStop)
 	})
 }
 
+// OnPanic задаёт наблюдателя за паниками (error tracking): он получает паники из RegisterRecovers,
+// utils.GoRecover и utils.Recover. Наблюдатель процесса один, повторный вызов его заменяет.
+func (app *App) OnPanic(observer func(value any, stack []byte)) {
+	utils.SetPanicObserver(observer)
+}
+
 func (app *App) RegisterRecovers() func() {
 	return func() {
 		if r := recover(); r != nil {
+			utils.NotifyPanic(r, debug.Stack())
 			logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
 				Msg:       "Panic happened in application",
 				Component: "application",
 				Method:    "RegisterRecovers",
 				Error:     fmt.Errorf("%v", r),
 			})
-			app.sig <- syscall.SIGTERM
+			app.TriggerShutdown()
 		}
 	}
 }
 
+// TriggerShutdown имитирует получение SIGTERM: запускает тот же путь, что и настоящий сигнал
+// (лог + cancel). Предназначен для тестов, где слать реальные сигналы процессу ненадёжно.
+// Работает только после Start. Если сигнал уже ожидает обработки, повторный не ставится.
+func (app *App) TriggerShutdown() {
+	select {
+	case app.sig <- syscall.SIGTERM:
+	default:
+	}
+}
+
+// IsShuttingDown true с самого начала App.Stop: обработчики и задачи могут пропустить дорогую работу.
+func (app *App) IsShuttingDown() bool {
+	return app.shuttingDown.Load()
+}
+
+type shutdownStateKey struct{}
+
+// WithShutdownState кладёт в ctx признак остановки приложения, читается через ShuttingDown(ctx).
+// Удобно в мидлваре или при создании контекста задач, где нет доступа к App.
+func (app *App) WithShutdownState(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shutdownStateKey{}, app)
+}
+
+// ShuttingDown сообщает, идёт ли остановка приложения, чьё состояние положено в ctx через WithShutdownState.
+func ShuttingDown(ctx context.Context) bool {
+	app, ok := ctx.Value(shutdownStateKey{}).(*App)
+	return ok && app.IsShuttingDown()
+}
+
 func (app *App) FlushLogger() {
 	logger.FlushLogs()
 }
@@ -0,0 +1,628 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/watchdog"
+)
+
+func newTestApp(t *testing.T, opts ...AppOption) *App {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	app := NewApp(ctx, 0, 100, opts...)
+	t.Cleanup(func() {
+		cancel()
+		app.supervisorsWG.Wait()
+	})
+	return app
+}
+
+// fakeWatchdog отдаёт заранее подготовленные каналы на каждый Elect
+type fakeWatchdog struct {
+	mu       sync.Mutex
+	elected  int
+	watchers []chan int
+	calls    chan struct{}
+}
+
+func newFakeWatchdog(watchers ...chan int) *fakeWatchdog {
+	return &fakeWatchdog{watchers: watchers, calls: make(chan struct{}, 16)}
+}
+
+func (w *fakeWatchdog) Elect(watchdog.Config) <-chan int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.elected++
+	w.calls <- struct{}{}
+	if len(w.watchers) == 0 {
+		return make(chan int)
+	}
+	ch := w.watchers[0]
+	w.watchers = w.watchers[1:]
+	return ch
+}
+
+func (w *fakeWatchdog) Stop() {}
+
+func TestClosedWatcherTriggersReElection(t *testing.T) {
+	app := newTestApp(t)
+
+	first := make(chan int, 1)
+	first <- watchdog.TakenAcquire
+	wd := newFakeWatchdog()
+
+	var mu sync.Mutex
+	var started, stopped int
+	app.RegisterWatchdogsLeadership(&LeaderSupervisor{
+		SupervisorName: "test",
+		Watcher:        first,
+		Watchdog:       wd,
+		ElectionConfig: watchdog.Config{ElectionName: "test"},
+		Start:          func() { mu.Lock(); started++; mu.Unlock() },
+		Stop:           func() { mu.Lock(); stopped++; mu.Unlock() },
+	})
+	app.StartWatchdogsLeadership()
+	close(first)
+
+	select {
+	case <-wd.calls:
+	case <-time.After(reElectMinBackoff + 2*time.Second):
+		t.Fatal("re-election was not attempted after the watcher closed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if started != 1 || stopped != 1 {
+		t.Fatalf("started %d, stopped %d; want the subsystem stopped once leadership was lost", started, stopped)
+	}
+}
+
+func TestStartCheckedReturnsEarlyWhenAllLead(t *testing.T) {
+	app := newTestApp(t)
+	watcher := make(chan int, 1)
+	watcher <- watchdog.TakenAcquire
+	app.RegisterWatchdogsLeadership(&LeaderSupervisor{
+		SupervisorName: "leader",
+		Watcher:        watcher,
+		Start:          func() {},
+		Stop:           func() {},
+	})
+
+	start := time.Now()
+	if err := app.StartWatchdogsLeadershipChecked(10 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("waited %s although the supervisor already leads", elapsed)
+	}
+}
+
+func TestStartCheckedFailsWhenSupervisorExits(t *testing.T) {
+	app := newTestApp(t)
+	watcher := make(chan int)
+	close(watcher) // без Watchdog повторных выборов не будет, потребитель завершится
+	app.RegisterWatchdogsLeadership(&LeaderSupervisor{
+		SupervisorName: "broken",
+		Watcher:        watcher,
+		Start:          func() {},
+		Stop:           func() {},
+	})
+
+	if err := app.StartWatchdogsLeadershipChecked(5 * time.Second); err == nil {
+		t.Fatal("want error for a supervisor that never starts")
+	}
+}
+
+func TestMustStartPanics(t *testing.T) {
+	app := newTestApp(t)
+	watcher := make(chan int)
+	close(watcher)
+	app.RegisterWatchdogsLeadership(&LeaderSupervisor{
+		SupervisorName: "broken",
+		Watcher:        watcher,
+		Start:          func() {},
+		Stop:           func() {},
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustStart did not panic")
+		}
+	}()
+	app.MustStart(5 * time.Second)
+}
+
+// startWithCancelCounter запускает обработку сигналов, cancel которой считает вызовы
+func startWithCancelCounter(t *testing.T, app *App) <-chan struct{} {
+	t.Helper()
+	cancelled := make(chan struct{}, 16)
+	app.Start(func() { cancelled <- struct{}{} })
+	t.Cleanup(app.stopSignals)
+	return cancelled
+}
+
+func waitCancel(t *testing.T, cancelled <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("signal did not reach cancel")
+	}
+}
+
+func TestSignalAfterHandledSignalIsStillProcessed(t *testing.T) {
+	app := newTestApp(t)
+	cancelled := startWithCancelCounter(t, app)
+
+	app.TriggerShutdown()
+	waitCancel(t, cancelled)
+	app.TriggerShutdown()
+	waitCancel(t, cancelled)
+}
+
+func TestRepeatedSignalsAreLoggedAsAlreadyShuttingDown(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+	cancelled := startWithCancelCounter(t, app)
+
+	for _, s := range []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGTERM} {
+		app.sig <- s
+		waitCancel(t, cancelled)
+	}
+
+	first := logs.WithMessage("Signal received. Shutting down application...")
+	if len(first) != 1 || first[0]["level"] != "info" || first[0]["args"] != "terminated" {
+		t.Fatalf("first signal entries %v", first)
+	}
+	for _, msg := range []string{
+		"Signal interrupt received while already shutting down (2 so far)",
+		"Signal terminated received while already shutting down (3 so far)",
+	} {
+		got := logs.WithMessage(msg)
+		if len(got) != 1 || got[0]["level"] != "warn" {
+			t.Fatalf("entries for %q: %v", msg, got)
+		}
+	}
+}
+
+// drainFunc адаптер функции к Drainable
+type drainFunc func(ctx context.Context) error
+
+func (f drainFunc) Drain(ctx context.Context) error { return f(ctx) }
+
+func TestStopDrainsInPriorityOrderBeforeShutdown(t *testing.T) {
+	app := newTestApp(t)
+
+	var order []string
+	record := func(name string) drainFunc {
+		return func(context.Context) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+	app.RegisterShutdown("hook", func() { order = append(order, "hook") }, 0)
+	app.RegisterDrainable("late", record("late"), 2)
+	app.RegisterDrainable("early", record("early"), 1)
+
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"early", "late", "hook"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("order %v, want %v", order, want)
+	}
+}
+
+func TestDrainAllSharesDeadline(t *testing.T) {
+	app := newTestApp(t)
+
+	secondCalled := false
+	app.RegisterDrainable("slow", drainFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), 1)
+	app.RegisterDrainable("next", drainFunc(func(context.Context) error {
+		secondCalled = true
+		return nil
+	}), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := app.DrainAll(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("DrainAll took %s past the deadline", elapsed)
+	}
+	if secondCalled {
+		t.Fatal("drainable ran after the shared deadline expired")
+	}
+}
+
+func TestEqualPriorityShutdownIsFIFO(t *testing.T) {
+	app := newTestApp(t)
+
+	var order []string
+	for _, reg := range []struct {
+		name     string
+		priority int
+	}{{"a1", 1}, {"b", 2}, {"a2", 1}, {"first", 0}, {"a3", 1}} {
+		app.RegisterShutdown(reg.name, func() { order = append(order, reg.name) }, reg.priority)
+	}
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "a1", "a2", "a3", "b"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("order %v, want %v", order, want)
+	}
+}
+
+func TestLIFOShutdownRunsInReverseAfterPriorityHooks(t *testing.T) {
+	app := newTestApp(t)
+
+	var order []string
+	app.RegisterShutdownLIFO("db", func() { order = append(order, "db") })
+	app.RegisterShutdown("http", func() { order = append(order, "http") }, 10)
+	app.RegisterShutdownLIFO("stmt-cache", func() { order = append(order, "stmt-cache") })
+	app.RegisterShutdownLIFO("consumer", func() { order = append(order, "consumer") })
+
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http", "consumer", "stmt-cache", "db"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("order %v, want %v", order, want)
+	}
+}
+
+func TestStopWaitsForWatcherConsumers(t *testing.T) {
+	app := newTestApp(t)
+
+	watcher := make(chan int, 1)
+	watcher <- watchdog.TakenAcquire
+	inStart := make(chan struct{})
+	supervisor := &LeaderSupervisor{
+		SupervisorName: "slow",
+		Watcher:        watcher,
+		Watchdog:       newFakeWatchdog(),
+		ElectionConfig: watchdog.Config{ElectionName: "slow"},
+		Start: func() {
+			close(inStart)
+			time.Sleep(50 * time.Millisecond)
+		},
+		Stop: func() {},
+	}
+	app.RegisterWatchdogsLeadership(supervisor)
+
+	var exitedBeforeHooks bool
+	app.RegisterShutdown("check", func() { exitedBeforeHooks = supervisor.exited.Load() }, 0)
+
+	app.StartWatchdogsLeadership()
+	<-inStart
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !exitedBeforeHooks {
+		t.Fatal("shutdown hooks ran while the watcher consumer was still running")
+	}
+}
+
+func TestOnShutdownHookRecordsOrder(t *testing.T) {
+	app := newTestApp(t)
+
+	var order []string
+	app.OnShutdownHook(func(name string) { order = append(order, name) })
+	app.RegisterShutdown("db", func() {}, 20)
+	app.RegisterShutdown("http", func() {}, 10)
+	app.RegisterShutdownLIFO("cache", func() {})
+	app.RegisterShutdownFunc("broken", func(context.Context) error { return errors.New("close failed") }, 15)
+
+	if err := app.Stop(); err == nil {
+		t.Fatal("Stop did not return the hook error")
+	}
+	want := []string{"http", "broken", "db", "cache"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("observed %v, want %v", order, want)
+	}
+}
+
+func TestStopJoinsRegisterShutdownEErrors(t *testing.T) {
+	app := newTestApp(t)
+
+	errDB := errors.New("db: close failed")
+	errRedis := errors.New("redis: connection reset")
+	app.RegisterShutdownE("db", func(context.Context) error { return errDB }, 0)
+	app.RegisterShutdown("http", func() {}, 1)
+	app.RegisterShutdownE("redis", func(context.Context) error { return errRedis }, 2)
+
+	err := app.Stop()
+	if !errors.Is(err, errDB) || !errors.Is(err, errRedis) {
+		t.Fatalf("Stop error %v, want both hook errors", err)
+	}
+}
+
+func TestRegisterShutdownFuncLogsError(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	closeErr := errors.New("connection reset")
+	var gotDeadline bool
+	app.RegisterShutdownFunc("redis", func(ctx context.Context) error {
+		_, gotDeadline = ctx.Deadline()
+		return closeErr
+	}, 0)
+	var nextRan bool
+	app.RegisterShutdown("next", func() { nextRan = true }, 1)
+
+	if err := app.Stop(); !errors.Is(err, closeErr) {
+		t.Fatalf("Stop error %v, want the hook error", err)
+	}
+	if !gotDeadline {
+		t.Fatal("hook context has no per-hook timeout")
+	}
+	if !nextRan {
+		t.Fatal("hook after the failing one did not run")
+	}
+	entries := logs.WithMessage("Shutdown func redis failed")
+	if len(entries) != 1 || entries[0]["error"] != closeErr.Error() {
+		t.Fatalf("error entries %v, want one with %q", entries, closeErr)
+	}
+}
+
+func TestTriggerShutdownRunsCancelAndShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	app := NewApp(ctx, 0, 100)
+
+	hookRan := false
+	app.RegisterShutdown("hook", func() { hookRan = true }, 0)
+	app.Start(cancel)
+
+	app.TriggerShutdown()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("TriggerShutdown did not cancel the application context")
+	}
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !hookRan {
+		t.Fatal("shutdown hook did not run")
+	}
+}
+
+func TestLoggerFlushRunsLastRegardlessOfRegistrationOrder(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		app := newTestApp(t)
+		app.ParallelShutdownTiers(parallel)
+
+		var mu sync.Mutex
+		var order []string
+		record := func(name string) func() {
+			return func() {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, name)
+			}
+		}
+		app.RegisterShutdown("logger", record("logger"), LoggerFlushPriority)
+		app.RegisterShutdownLIFO("db", record("db"))
+		app.RegisterShutdown("http", record("http"), LowestPriority)
+		app.RegisterShutdown("cache", record("cache"), ImmediatePriority)
+
+		if err := app.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if len(order) != 4 || order[len(order)-1] != "logger" {
+			t.Fatalf("parallel=%v: order %v, want logger last", parallel, order)
+		}
+	}
+}
+
+func TestWarnShutdownOrderFlagsMisplacedLoggerFlush(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	app.RegisterShutdown("logger-sync", func() {}, HighPriority)
+	app.WarnShutdownOrder(true)
+	app.RegisterShutdown("zap-flush", func() {}, HighPriority)
+	app.RegisterShutdown("logger", func() {}, LoggerFlushPriority)
+
+	warned := func(name string) bool {
+		return len(logs.WithMessage("Shutdown func "+name+" looks like a logger flush, register it with LoggerFlushPriority to run last")) > 0
+	}
+	if warned("logger-sync") {
+		t.Fatal("warning emitted while the check was disabled")
+	}
+	if !warned("zap-flush") {
+		t.Fatal("no warning for a logger flush registered with a regular priority")
+	}
+	if warned("logger") {
+		t.Fatal("warning for a hook registered with LoggerFlushPriority")
+	}
+}
+
+func TestBlockingShutdownHookTimesOut(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	hookCtxErr := make(chan error, 1)
+	// хук игнорирует отмену и висит, как зависший Close
+	app.RegisterShutdownWithTimeout("db", func(ctx context.Context) {
+		<-ctx.Done()
+		hookCtxErr <- ctx.Err()
+		<-release
+	}, 0, 50*time.Millisecond)
+	var nextRan bool
+	app.RegisterShutdown("next", func() { nextRan = true }, 1)
+
+	start := time.Now()
+	err := app.Stop()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Stop took %s with a 50ms hook timeout", elapsed)
+	}
+	if !errors.Is(err, errShutdownTimeout) {
+		t.Fatalf("Stop error %v, want the hook timeout", err)
+	}
+	if !nextRan {
+		t.Fatal("hook after the blocking one did not run")
+	}
+	if err := <-hookCtxErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("hook ctx error %v, want DeadlineExceeded", err)
+	}
+	if entries := logs.WithMessage("Shutdown func db did not finish in 50ms, moving on"); len(entries) != 1 || entries[0]["level"] != "warn" {
+		t.Fatalf("timeout entries %v, want one warning", entries)
+	}
+}
+
+func TestPanickingShutdownHookDoesNotStopOthers(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	var order []string
+	app.RegisterShutdown("broken", func() { panic("close on nil pool") }, 0)
+	app.RegisterShutdown("http", func() { order = append(order, "http") }, 1)
+	app.RegisterShutdownLIFO("db", func() { order = append(order, "db") })
+
+	err := app.Stop()
+	if err == nil || !strings.Contains(err.Error(), "close on nil pool") {
+		t.Fatalf("Stop error %v, want the hook panic", err)
+	}
+	if want := []string{"http", "db"}; !slices.Equal(order, want) {
+		t.Fatalf("ran %v after the panic, want %v", order, want)
+	}
+	entries := logs.WithMessage("Shutdown func broken panicked")
+	if len(entries) != 1 {
+		t.Fatalf("%d panic entries, want 1", len(entries))
+	}
+	if args, _ := entries[0]["args"].(string); !strings.Contains(args, "goroutine ") {
+		t.Fatalf("panic entry has no stack: %q", args)
+	}
+}
+
+func TestRepanicOnShutdownPanicAfterAllHooks(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+	app.RepanicOnShutdownPanic(true)
+
+	var lastRan bool
+	app.RegisterShutdown("broken", func() { panic("first") }, 0)
+	app.RegisterShutdown("also-broken", func() { panic("second") }, 1)
+	app.RegisterShutdown("last", func() { lastRan = true }, 2)
+
+	defer func() {
+		if r := recover(); r != "first" {
+			t.Fatalf("recovered %v, want the first hook panic", r)
+		}
+		if !lastRan {
+			t.Fatal("Stop re-panicked before running the remaining hooks")
+		}
+	}()
+	_ = app.Stop()
+	t.Fatal("Stop did not re-panic")
+}
+
+func TestDeregisterShutdownHeadMiddleTail(t *testing.T) {
+	for _, tc := range []struct {
+		remove string
+		want   []string
+	}{
+		{"a", []string{"b", "c", "d"}},
+		{"b", []string{"a", "c", "d"}},
+		{"d", []string{"a", "b", "c"}},
+	} {
+		app := newTestApp(t)
+		var order []string
+		app.OnShutdownHook(func(name string) { order = append(order, name) })
+		for i, name := range []string{"a", "b", "c", "d"} {
+			app.RegisterShutdown(name, func() {}, i)
+		}
+
+		if !app.DeregisterShutdown(tc.remove) {
+			t.Fatalf("%s not found", tc.remove)
+		}
+		if app.DeregisterShutdown(tc.remove) {
+			t.Fatalf("%s removed twice", tc.remove)
+		}
+		if err := app.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(order, tc.want) {
+			t.Fatalf("removing %s: ran %v, want %v", tc.remove, order, tc.want)
+		}
+	}
+}
+
+func TestDeregisterShutdownRemovesFirstMatchAndLIFO(t *testing.T) {
+	app := newTestApp(t)
+	var order []string
+	app.RegisterShutdown("conn", func() { order = append(order, "old conn") }, 0)
+	app.RegisterShutdown("conn", func() { order = append(order, "new conn") }, 1)
+	app.RegisterShutdownLIFO("cache", func() { order = append(order, "cache") })
+
+	if !app.DeregisterShutdown("conn") || !app.DeregisterShutdown("cache") {
+		t.Fatal("registered hooks not found")
+	}
+	if app.DeregisterShutdown("missing") {
+		t.Fatal("unknown hook reported as removed")
+	}
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"new conn"}; !slices.Equal(order, want) {
+		t.Fatalf("ran %v, want %v", order, want)
+	}
+}
+
+func TestCustomShutdownSignalSet(t *testing.T) {
+	app := newTestApp(t, WithShutdownSignals(syscall.SIGUSR2))
+	// SIGINT ловим сами, иначе без подписки App рантайм завершил бы тестовый процесс
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGINT)
+	t.Cleanup(func() { signal.Stop(guard) })
+	cancelled := startWithCancelCounter(t, app)
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-guard:
+	case <-time.After(time.Second):
+		t.Fatal("SIGINT not delivered")
+	}
+	select {
+	case <-cancelled:
+		t.Fatal("SIGINT outside the configured set triggered shutdown")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	waitCancel(t, cancelled)
+}
+
+func TestEmptyShutdownSignalsKeepDefaults(t *testing.T) {
+	app := newTestApp(t, WithShutdownSignals())
+	want := []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT}
+	if !slices.Equal(app.signals, want) {
+		t.Fatalf("signals %v, want %v", app.signals, want)
+	}
+}
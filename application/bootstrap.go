@@ -0,0 +1,84 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+const (
+	DefaultBootstrapStepTimeout = 30 * time.Second
+	bootstrapReadyPollInterval  = 500 * time.Millisecond
+)
+
+// BootstrapStep шаг запуска для App.Bootstrap: поднять зависимость (Start) и дождаться её готовности (Ready).
+type BootstrapStep struct {
+	Name string
+	// Start поднимает зависимость (подключение к БД, запуск сервера). nil — шаг только ждёт Ready.
+	Start func(ctx context.Context) error
+	// Ready проверка готовности (ping): повторяется, пока не вернёт nil или не выйдет Timeout. nil — без проверки.
+	Ready func(ctx context.Context) error
+	// Timeout на Start и Ready вместе, <= 0 — DefaultBootstrapStepTimeout.
+	Timeout time.Duration
+}
+
+// Bootstrap выполняет шаги по порядку и останавливается на первом неудачном: следующие шаги (серверы,
+// переключение барьера готовности в ready) не запускаются, если зависимость не поднялась за свой таймаут.
+// Возвращает ошибку с именем шага; уже поднятые зависимости закрываются обычными shutdown-хуками.
+func (app *App) Bootstrap(steps []BootstrapStep) error {
+	for i, step := range steps {
+		start := time.Now()
+		if err := app.runBootstrapStep(step); err != nil {
+			logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Bootstrap step %s failed", step.Name),
+				Error:     err,
+				Component: "application",
+				Method:    "Bootstrap",
+				Fields:    map[string]any{"step": step.Name, "index": i, "duration": time.Since(start)},
+			})
+			return fmt.Errorf("bootstrap step %d (%s): %w", i, step.Name, err)
+		}
+		logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("Bootstrap step %s is ready", step.Name),
+			Component: "application",
+			Method:    "Bootstrap",
+			Fields:    map[string]any{"step": step.Name, "index": i, "duration": time.Since(start)},
+		})
+	}
+	return nil
+}
+
+func (app *App) runBootstrapStep(step BootstrapStep) error {
+	timeout := step.Timeout
+	if timeout <= 0 {
+		timeout = DefaultBootstrapStepTimeout
+	}
+	ctx, cancel := context.WithTimeout(app.ctx, timeout)
+	defer cancel()
+
+	if step.Start != nil {
+		if err := step.Start(ctx); err != nil {
+			return fmt.Errorf("start: %w", err)
+		}
+	}
+	if step.Ready == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(bootstrapReadyPollInterval)
+	defer ticker.Stop()
+	for {
+		err := step.Ready(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
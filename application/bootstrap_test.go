@@ -0,0 +1,110 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+func TestBootstrapRunsStepsInOrder(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	var order []string
+	step := func(name string) BootstrapStep {
+		return BootstrapStep{
+			Name: name,
+			Start: func(context.Context) error {
+				order = append(order, "start "+name)
+				return nil
+			},
+			Ready: func(context.Context) error {
+				order = append(order, "ready "+name)
+				return nil
+			},
+		}
+	}
+	// шаг без Start только ждёт готовности, и первая проверка не проходит
+	var pings int
+	waitOnly := BootstrapStep{
+		Name: "clickhouse",
+		Ready: func(context.Context) error {
+			pings++
+			order = append(order, "ready clickhouse")
+			if pings == 1 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}
+
+	if err := app.Bootstrap([]BootstrapStep{step("postgres"), waitOnly, step("http")}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start postgres", "ready postgres", "ready clickhouse", "ready clickhouse", "start http", "ready http"}
+	if !slices.Equal(order, want) {
+		t.Fatalf("order %v, want %v", order, want)
+	}
+	for _, name := range []string{"postgres", "clickhouse", "http"} {
+		entries := logs.WithMessage("Bootstrap step " + name + " is ready")
+		if len(entries) != 1 || entries[0]["step"] != name {
+			t.Fatalf("ready log entries for %s: %v", name, entries)
+		}
+	}
+}
+
+func TestBootstrapFailingStartAbortsRemainingSteps(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	errDial := errors.New("dial tcp: connection refused")
+	var serverStarted bool
+	err := app.Bootstrap([]BootstrapStep{
+		{Name: "postgres", Start: func(context.Context) error { return errDial }},
+		{Name: "http", Start: func(context.Context) error {
+			serverStarted = true
+			return nil
+		}},
+	})
+	if !errors.Is(err, errDial) || !strings.Contains(err.Error(), "bootstrap step 0 (postgres)") {
+		t.Fatalf("error %v, want the postgres start failure", err)
+	}
+	if serverStarted {
+		t.Fatal("step after the failed one was started")
+	}
+	entries := logs.WithMessage("Bootstrap step postgres failed")
+	if len(entries) != 1 || entries[0]["level"] != "error" || entries[0]["step"] != "postgres" {
+		t.Fatalf("failure log entries %v", entries)
+	}
+}
+
+func TestBootstrapReadyTimeoutAbortsWithLastError(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	errPing := errors.New("ping: connection refused")
+	var serverStarted bool
+	start := time.Now()
+	err := app.Bootstrap([]BootstrapStep{
+		{Name: "redis", Ready: func(context.Context) error { return errPing }, Timeout: 100 * time.Millisecond},
+		{Name: "http", Start: func(context.Context) error {
+			serverStarted = true
+			return nil
+		}},
+	})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Bootstrap took %s with a 100ms step timeout", elapsed)
+	}
+	if !errors.Is(err, errPing) || !strings.Contains(err.Error(), "(redis): not ready after 100ms") {
+		t.Fatalf("error %v, want the redis readiness timeout", err)
+	}
+	if serverStarted {
+		t.Fatal("step after the timed out one was started")
+	}
+}
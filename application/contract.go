@@ -0,0 +1,9 @@
+package application
+
+import "context"
+
+// Drainable компонент, которому перед остановкой нужно дописать буферы и доделать начатую работу,
+// при этом его ресурсы должны оставаться открытыми до конца Drain.
+type Drainable interface {
+	Drain(ctx context.Context) error
+}
@@ -0,0 +1,107 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+// CPUQuota возвращает лимит CPU контейнера в ядрах (1.5 — полтора ядра). ok == false — лимита нет.
+type CPUQuota func() (cores float64, ok bool, err error)
+
+// WithAutoMaxProcs выставляет GOMAXPROCS по лимиту CPU контейнера, если в NewApp передано cores <= 0.
+// Явное cores > 0 по-прежнему имеет приоритет. quota == nil — CgroupCPUQuota.
+func WithAutoMaxProcs(quota CPUQuota) AppOption {
+	return func(app *App) {
+		if quota == nil {
+			quota = CgroupCPUQuota
+		}
+		app.cpuQuota = quota
+	}
+}
+
+// CgroupCPUQuota читает лимит из cgroup v2 (cpu.max), а если его нет — из cgroup v1 (cpu.cfs_quota_us / cpu.cfs_period_us).
+func CgroupCPUQuota() (float64, bool, error) {
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 {
+			return 0, false, fmt.Errorf("unexpected cpu.max: %q", data)
+		}
+		if fields[0] == "max" {
+			return 0, false, nil
+		}
+		return cpuQuota(fields[0], fields[1])
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, false, err
+	}
+
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false, err
+	}
+	return cpuQuota(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func cpuQuota(quota, period string) (float64, bool, error) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("parse quota: %w", err)
+	}
+	// в cgroup v1 -1 означает «без лимита»
+	if q <= 0 {
+		return 0, false, nil
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false, fmt.Errorf("parse period %q: %v", period, err)
+	}
+	return q / p, true, nil
+}
+
+// maxProcs значение GOMAXPROCS для NewApp: явное cores, иначе лимит из quota (вниз, но не меньше 1),
+// иначе 0 — оставить как есть.
+func maxProcs(ctx context.Context, cores int, quota CPUQuota) int {
+	if cores > 0 || quota == nil {
+		return cores
+	}
+	limit, ok, err := quota()
+	if err != nil {
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       "Failed to read CPU quota, GOMAXPROCS left unchanged",
+			Component: "application",
+			Method:    "NewApp",
+			Error:     err,
+		})
+		return 0
+	}
+	if !ok {
+		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("No CPU quota, GOMAXPROCS left at %d", runtime.GOMAXPROCS(0)),
+			Component: "application",
+			Method:    "NewApp",
+		})
+		return 0
+	}
+	procs := max(int(math.Floor(limit)), 1)
+	logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+		Msg:       fmt.Sprintf("GOMAXPROCS set to %d from CPU quota %.2f", procs, limit),
+		Component: "application",
+		Method:    "NewApp",
+	})
+	return procs
+}
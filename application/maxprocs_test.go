@@ -0,0 +1,71 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+// fakeQuota источник лимита CPU с заранее заданным ответом
+func fakeQuota(cores float64, ok bool, err error) CPUQuota {
+	return func() (float64, bool, error) { return cores, ok, err }
+}
+
+func TestMaxProcsFromQuota(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cores int
+		quota CPUQuota
+		want  int
+	}{
+		{"fractional quota rounds down", 0, fakeQuota(2.7, true, nil), 2},
+		{"quota below one core", 0, fakeQuota(0.5, true, nil), 1},
+		{"explicit cores override quota", 8, fakeQuota(2, true, nil), 8},
+		{"no quota", 0, fakeQuota(0, false, nil), 0},
+		{"quota read error", 0, fakeQuota(0, false, errors.New("permission denied")), 0},
+		{"option not set", 0, nil, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := maxProcs(context.Background(), tc.cores, tc.quota); got != tc.want {
+				t.Fatalf("maxProcs = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithAutoMaxProcsLogsDecision(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	prev := runtime.GOMAXPROCS(0)
+	t.Cleanup(func() { runtime.GOMAXPROCS(prev) })
+
+	newTestApp(t, WithAutoMaxProcs(fakeQuota(1.5, true, nil)))
+
+	if got := runtime.GOMAXPROCS(0); got != 1 {
+		t.Fatalf("GOMAXPROCS %d, want 1 from a 1.5 core quota", got)
+	}
+	if len(logs.WithMessage("GOMAXPROCS set to 1 from CPU quota 1.50")) != 1 {
+		t.Fatal("quota decision not logged")
+	}
+}
+
+func TestCPUQuotaParsing(t *testing.T) {
+	for _, tc := range []struct {
+		quota, period string
+		want          float64
+		ok, err       bool
+	}{
+		{"150000", "100000", 1.5, true, false},
+		{"-1", "100000", 0, false, false},
+		{"abc", "100000", 0, false, true},
+		{"50000", "0", 0, false, true},
+	} {
+		got, ok, err := cpuQuota(tc.quota, tc.period)
+		if got != tc.want || ok != tc.ok || (err != nil) != tc.err {
+			t.Errorf("cpuQuota(%q, %q) = %v, %v, %v", tc.quota, tc.period, got, ok, err)
+		}
+	}
+}
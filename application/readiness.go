@@ -0,0 +1,68 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/readiness_barrier"
+)
+
+const readinessSignalTimeout = time.Second
+
+// LinkLeadershipToReadiness связывает лидерство супервизора с барьером готовности:
+// после Start (TakenAcquire) сервис становится ready, после Stop (LostAcquire или остановка) — not_ready.
+// Нужен для singleton-нагрузок, где не-лидер не должен принимать трафик. Вызывать до RegisterWatchdogsLeadership.
+func LinkLeadershipToReadiness(supervisor *LeaderSupervisor, barrier readiness_barrier.ReadinessBarrierInterface) {
+	start, stop := supervisor.Start, supervisor.Stop
+
+	supervisor.Start = func() {
+		if start != nil {
+			start()
+		}
+		sendReadiness(supervisor.SupervisorName, "ready", func(ctx context.Context) error {
+			return barrier.SendSignalCtx(ctx, readiness_barrier.ReadySignalToggle)
+		})
+	}
+	supervisor.Stop = func() {
+		sendReadiness(supervisor.SupervisorName, "not_ready", func(ctx context.Context) error {
+			return barrier.SendSignalCtx(ctx, readiness_barrier.NotReadySignalToggle)
+		})
+		if stop != nil {
+			stop()
+		}
+	}
+}
+
+func sendReadiness(name, state string, send func(ctx context.Context) error) {
+	// не на контексте приложения: при остановке он уже отменён, а not_ready всё равно нужно выставить
+	ctx, cancel := context.WithTimeout(context.Background(), readinessSignalTimeout)
+	defer cancel()
+
+	if err := send(ctx); err != nil {
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("Supervisor %s failed to update readiness", name),
+			Component: "application",
+			Method:    "LinkLeadershipToReadiness",
+			Args:      state,
+			Error:     err,
+		})
+	}
+}
+
+// ReadinessHandler HTTP-проба готовности: 200, если барьер в ready и приложение не останавливается, иначе 503.
+// С началом App.Stop отвечает 503 сразу, не дожидаясь сигнала not_ready в барьер.
+func (app *App) ReadinessHandler(barrier readiness_barrier.ReadinessBarrierInterface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.IsShuttingDown() || !barrier.IsReady() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("not_ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready"))
+	})
+}
@@ -0,0 +1,125 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/readiness_barrier"
+	"github.com/PavelAgarkov/service-pkg/watchdog"
+)
+
+func waitReady(t *testing.T, barrier *readiness_barrier.ReadinessBarrier, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for barrier.IsReady() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("readiness %v, want %v", barrier.IsReady(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLeadershipDrivesReadiness(t *testing.T) {
+	app := newTestApp(t)
+	barrier := readiness_barrier.NewReadinessBarrier(context.Background(), readiness_barrier.ReadinessBarrierConfig{Name: "leader"})
+	barrier.Start()
+	t.Cleanup(barrier.Stop)
+
+	watcher := make(chan int)
+	supervisor := &LeaderSupervisor{
+		SupervisorName: "singleton",
+		Watcher:        watcher,
+		Watchdog:       newFakeWatchdog(),
+		ElectionConfig: watchdog.Config{ElectionName: "singleton"},
+		Start:          func() {},
+		Stop:           func() {},
+	}
+	LinkLeadershipToReadiness(supervisor, barrier)
+	app.RegisterWatchdogsLeadership(supervisor)
+	app.StartWatchdogsLeadership()
+
+	waitReady(t, barrier, false)
+	watcher <- watchdog.TakenAcquire
+	waitReady(t, barrier, true)
+	watcher <- watchdog.LostAcquire
+	waitReady(t, barrier, false)
+	watcher <- watchdog.TakenAcquire
+	waitReady(t, barrier, true)
+}
+
+func TestLinkLeadershipKeepsOriginalCallbacks(t *testing.T) {
+	barrier := readiness_barrier.NewReadinessBarrier(context.Background(), readiness_barrier.ReadinessBarrierConfig{Name: "leader"})
+	barrier.Start()
+	t.Cleanup(barrier.Stop)
+
+	var started, stopped bool
+	supervisor := &LeaderSupervisor{
+		SupervisorName: "singleton",
+		Start:          func() { started = true },
+		Stop:           func() { stopped = true },
+	}
+	LinkLeadershipToReadiness(supervisor, barrier)
+
+	supervisor.Start()
+	waitReady(t, barrier, true)
+	supervisor.Stop()
+	waitReady(t, barrier, false)
+	if !started || !stopped {
+		t.Fatalf("started %v, stopped %v; original callbacks must run", started, stopped)
+	}
+}
+
+func TestReadinessHandlerReportsNotReadyOnceShuttingDown(t *testing.T) {
+	app := newTestApp(t)
+	barrier := readiness_barrier.NewReadinessBarrier(context.Background(), readiness_barrier.ReadinessBarrierConfig{Name: "app"})
+	barrier.Start()
+	t.Cleanup(barrier.Stop)
+	if err := barrier.SendSignalCtx(context.Background(), readiness_barrier.ReadySignalToggle); err != nil {
+		t.Fatal(err)
+	}
+	waitReady(t, barrier, true)
+
+	handler := app.ReadinessHandler(barrier)
+	probe := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		return rec.Code
+	}
+	ctx := app.WithShutdownState(context.Background())
+	if app.IsShuttingDown() || ShuttingDown(ctx) {
+		t.Fatal("shutting down before Stop")
+	}
+	if code := probe(); code != http.StatusOK {
+		t.Fatalf("probe %d before Stop, want 200", code)
+	}
+
+	// первый хук видит уже выставленный флаг: он ставится в самом начале Stop
+	var inHook struct {
+		app, ctx bool
+		code     int
+	}
+	app.RegisterShutdown("first", func() {
+		inHook.app = app.IsShuttingDown()
+		inHook.ctx = ShuttingDown(ctx)
+		inHook.code = probe()
+	}, ImmediatePriority)
+	if err := app.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !inHook.app || !inHook.ctx {
+		t.Fatalf("IsShuttingDown %v, ShuttingDown(ctx) %v during Stop", inHook.app, inHook.ctx)
+	}
+	if inHook.code != http.StatusServiceUnavailable {
+		t.Fatalf("probe %d during Stop, want 503 while the barrier is still ready", inHook.code)
+	}
+	if !barrier.IsReady() {
+		t.Fatal("barrier flipped, the test no longer isolates the shutdown flag")
+	}
+	if ShuttingDown(context.Background()) {
+		t.Fatal("ShuttingDown true for a context without app state")
+	}
+}
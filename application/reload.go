@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"go.uber.org/zap/zapcore"
+)
+
+type reloader struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// OnReload регистрирует хук перезагрузки конфигурации, вызываемый по SIGHUP (после Start) или через Reload.
+// Хуки выполняются в порядке регистрации, ошибка одного не мешает остальным.
+func (app *App) OnReload(name string, fn func(ctx context.Context) error) {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+	app.reloaders = append(app.reloaders, reloader{name: name, fn: fn})
+}
+
+// Reload выполняет хуки OnReload сразу, как при SIGHUP. Ошибки логируются и возвращаются вместе.
+func (app *App) Reload() error {
+	app.reloadMu.Lock()
+	reloaders := append([]reloader(nil), app.reloaders...)
+	app.reloadMu.Unlock()
+
+	logger.WriteInfoLog(app.ctx, &logger_wrapper.LogEntry{
+		Msg:       "Reloading configuration",
+		Component: "application",
+		Method:    "Reload",
+		Args:      len(reloaders),
+	})
+
+	var errs []error
+	for _, r := range reloaders {
+		if err := r.fn(app.ctx); err != nil {
+			logger.WriteErrorLog(app.ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Reload hook %s failed", r.name),
+				Component: "application",
+				Method:    "Reload",
+				Error:     err,
+			})
+			errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReloadLogLevelFromFile при каждой перезагрузке читает из path уровень логгера (одно слово: debug, info, warn...)
+// и применяет его через SetLevel. Файл с неизвестным уровнем не меняет текущий уровень.
+func (app *App) ReloadLogLevelFromFile(path string) {
+	app.OnReload("log_level_file", func(ctx context.Context) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		text := strings.TrimSpace(string(data))
+		level, err := zapcore.ParseLevel(text)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q in %s: %w", text, path, err)
+		}
+		if err = logger.SetLevel(level.String()); err != nil {
+			return err
+		}
+		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       "Log level reloaded",
+			Component: "application",
+			Method:    "ReloadLogLevelFromFile",
+			Args:      level.String(),
+		})
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package application
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+// writeLevelFile пишет уровень в файл во временной директории теста
+func writeLevelFile(t *testing.T, level string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log_level")
+	if err := os.WriteFile(path, []byte(level+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReloadLogLevelFromFileOnSIGHUP(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	if err := logger.SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t)
+	app.ReloadLogLevelFromFile(writeLevelFile(t, "debug"))
+	startWithCancelCounter(t, app)
+
+	app.reloadSig <- syscall.SIGHUP
+
+	deadline := time.Now().Add(time.Second)
+	for len(logs.WithMessage("Log level reloaded")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("level not reloaded, still %s", logger.GetLevel())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := logger.GetLevel(); got != "debug" {
+		t.Fatalf("level %s, want debug", got)
+	}
+}
+
+func TestReloadLogLevelFromFileRejectsUnknownLevel(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+	if err := logger.SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t)
+	app.ReloadLogLevelFromFile(writeLevelFile(t, "loud"))
+
+	if err := app.Reload(); err == nil {
+		t.Fatal("Reload accepted an unknown level")
+	}
+	if got := logger.GetLevel(); got != "info" {
+		t.Fatalf("level changed to %s by an invalid file", got)
+	}
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+// DBResource подключение к хранилищу (ClickHouse, Postgres, Redis), которое умеет закрыться:
+// перестать выдавать соединения, дождаться запросов в работе и закрыть пул. По ctx — прекратить ожидание.
+type DBResource interface {
+	Close(ctx context.Context) error
+}
+
+// DBResourceFunc адаптер функции к DBResource, например для redis.Client:
+// DBResourceFunc(func(context.Context) error { return client.Close() }).
+type DBResourceFunc func(ctx context.Context) error
+
+func (f DBResourceFunc) Close(ctx context.Context) error {
+	return f(ctx)
+}
+
+// ResourceGroup закрывает набор DBResource одним вызовом в порядке, обратном добавлению:
+// то, что добавлено позже (и может зависеть от ранее добавленного), закрывается первым.
+type ResourceGroup struct {
+	mu        sync.Mutex
+	resources []namedResource
+}
+
+type namedResource struct {
+	name     string
+	resource DBResource
+}
+
+func NewResourceGroup() *ResourceGroup {
+	return &ResourceGroup{}
+}
+
+func (g *ResourceGroup) Add(name string, r DBResource) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.resources = append(g.resources, namedResource{name: name, resource: r})
+}
+
+// CloseAll закрывает все ресурсы под общим дедлайном ctx. Ошибка одного не мешает закрыть остальные,
+// все ошибки возвращаются вместе (errors.Join). Если ctx истёк, зависший Close не ждём и переходим к следующему.
+func (g *ResourceGroup) CloseAll(ctx context.Context) error {
+	g.mu.Lock()
+	resources := g.resources
+	g.resources = nil
+	g.mu.Unlock()
+
+	var errs []error
+	for i := len(resources) - 1; i >= 0; i-- {
+		r := resources[i]
+		if err := closeResource(ctx, r.resource); err != nil {
+			logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Failed to close resource %s", r.name),
+				Error:     err,
+				Component: "application",
+				Method:    "ResourceGroup.CloseAll",
+			})
+			errs = append(errs, fmt.Errorf("close %s: %w", r.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func closeResource(ctx context.Context, r DBResource) error {
+	done := make(chan error, 1)
+	utils.GoRecover(context.WithoutCancel(ctx), func(context.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- utils.PanicError(p)
+			}
+		}()
+		done <- r.Close(ctx)
+	})
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RegisterResources регистрирует закрытие группы одним хуком остановки; timeout — общий дедлайн на всю группу
+// (<= 0 — DefaultShutdownHookTimeout). Ошибки закрытия попадают в общую ошибку App.Stop.
+func (app *App) RegisterResources(name string, g *ResourceGroup, priority int, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultShutdownHookTimeout
+	}
+	app.registerShutdown(name, g.CloseAll, priority, timeout)
+}
@@ -0,0 +1,129 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+func TestCloseAllClosesEveryResourceAndJoinsErrors(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	var closed []string
+	record := func(name string, err error) DBResourceFunc {
+		return func(context.Context) error {
+			closed = append(closed, name)
+			return err
+		}
+	}
+	errRedis := errors.New("redis: connection reset")
+	errPostgres := errors.New("postgres: pool busy")
+
+	g := NewResourceGroup()
+	g.Add("clickhouse", record("clickhouse", nil))
+	g.Add("postgres", record("postgres", errPostgres))
+	g.Add("redis", record("redis", errRedis))
+
+	err := g.CloseAll(context.Background())
+	if want := []string{"redis", "postgres", "clickhouse"}; !slices.Equal(closed, want) {
+		t.Fatalf("closed %v, want %v", closed, want)
+	}
+	if !errors.Is(err, errRedis) || !errors.Is(err, errPostgres) {
+		t.Fatalf("error %v does not carry both failures", err)
+	}
+	if !strings.Contains(err.Error(), "close redis") || !strings.Contains(err.Error(), "close postgres") {
+		t.Fatalf("error %q does not name the failed resources", err)
+	}
+	if entries := logs.WithMessage("Failed to close resource postgres"); len(entries) != 1 || entries[0]["level"] != "error" {
+		t.Fatalf("failure log entries %v", entries)
+	}
+
+	// группа опустошается: повторный вызов ничего не закрывает
+	closed = nil
+	if err := g.CloseAll(context.Background()); err != nil || len(closed) != 0 {
+		t.Fatalf("second CloseAll: err=%v, closed %v", err, closed)
+	}
+}
+
+func TestCloseAllTurnsPanicIntoError(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+
+	var dbClosed bool
+	g := NewResourceGroup()
+	g.Add("db", DBResourceFunc(func(context.Context) error {
+		dbClosed = true
+		return nil
+	}))
+	g.Add("broken", DBResourceFunc(func(context.Context) error { panic("nil pool") }))
+
+	err := g.CloseAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "close broken") || !strings.Contains(err.Error(), "nil pool") {
+		t.Fatalf("error %v, want the panic of broken", err)
+	}
+	if !dbClosed {
+		t.Fatal("resource after the panicking one was not closed")
+	}
+}
+
+func TestCloseAllStopsWaitingOnSharedDeadline(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	dbCalled := make(chan struct{})
+
+	g := NewResourceGroup()
+	g.Add("db", DBResourceFunc(func(context.Context) error {
+		close(dbCalled)
+		return nil
+	}))
+	// ресурс игнорирует ctx и висит
+	g.Add("stuck", DBResourceFunc(func(context.Context) error {
+		<-release
+		return nil
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := g.CloseAll(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("CloseAll took %s with a 50ms deadline", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "close stuck") {
+		t.Fatalf("error %v, want the deadline of stuck", err)
+	}
+	select {
+	case <-dbCalled:
+	case <-time.After(time.Second):
+		t.Fatal("resource after the stuck one was not closed")
+	}
+}
+
+func TestRegisterResourcesReportsErrorsFromStop(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+	app := newTestApp(t)
+
+	errRedis := errors.New("redis: connection reset")
+	var dbClosed bool
+	g := NewResourceGroup()
+	g.Add("db", DBResourceFunc(func(context.Context) error {
+		dbClosed = true
+		return nil
+	}))
+	g.Add("redis", DBResourceFunc(func(context.Context) error { return errRedis }))
+	app.RegisterResources("storage", g, 0, time.Second)
+
+	if err := app.Stop(); !errors.Is(err, errRedis) {
+		t.Fatalf("Stop error %v, want the redis close error", err)
+	}
+	if !dbClosed {
+		t.Fatal("db was not closed by the shutdown hook")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -26,12 +29,31 @@ type Clickhouse struct {
 	MaxIdleConn     int           `mapstructure:"max_idle_conn" envconfig:"MAX_IDLE_CONN"`
 	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time" envconfig:"CONN_MAX_IDLE_TIME"`
 	ConnMaxLifeTime time.Duration `mapstructure:"conn_max_life_time" envconfig:"CONN_MAX_LIFE_TIME"`
+	// ConnMaxLifeTimeJitter разброс срока жизни соединения: каждое живёт ConnMaxLifeTime ± jitter,
+	// чтобы пул не переподключался целиком в один момент. 0 — 10% от ConnMaxLifeTime, отрицательное — без разброса.
+	ConnMaxLifeTimeJitter time.Duration `mapstructure:"conn_max_life_time_jitter" envconfig:"CONN_MAX_LIFE_TIME_JITTER"`
+	Database              string        `mapstructure:"database" envconfig:"DATABASE"`
+	// Hosts дополнительные адреса "host:port" кластера, к основному Host:Port
+	Hosts []string `mapstructure:"hosts" envconfig:"HOSTS"`
+	// Compression lz4 (по умолчанию), zstd или none
+	Compression string `mapstructure:"compression" envconfig:"COMPRESSION"`
 }
 
 type Connection struct {
 	mu   sync.Mutex
 	conn *sql.DB
 	cfg  Clickhouse
+
+	// inflight текущее переподключение (под mu): конкурентные вызовы ждут его результат, а не создают свой пул
+	inflight *reconnectCall
+}
+
+// openConnection создание нового пула при переподключении, подменяется в тестах
+var openConnection = NewClickhouseConnection
+
+type reconnectCall struct {
+	done chan struct{}
+	err  error
 }
 
 func NewClickhouseConnection(ctx context.Context, cfg Clickhouse) (*Connection, error) {
@@ -50,14 +72,16 @@ func NewClickhouseConnection(ctx context.Context, cfg Clickhouse) (*Connection,
 	if cfg.ConnMaxLifeTime == 0 {
 		cfg.ConnMaxLifeTime = 24 * time.Hour
 	}
+	if cfg.ConnMaxLifeTimeJitter == 0 {
+		cfg.ConnMaxLifeTimeJitter = cfg.ConnMaxLifeTime / 10
+	}
 
-	host := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 	opt := &clickhouse.Options{
-		Addr:        []string{host},
-		Auth:        clickhouse.Auth{Username: cfg.Username, Password: cfg.Password},
+		Addr:        cfg.addrs(),
+		Auth:        clickhouse.Auth{Database: cfg.Database, Username: cfg.Username, Password: cfg.Password},
 		DialTimeout: cfg.DialTimeout,
 		Protocol:    clickhouse.Native,
-		Compression: &clickhouse.Compression{Method: clickhouse.CompressionLZ4},
+		Compression: compression(cfg.Compression),
 	}
 
 	var (
@@ -65,11 +89,16 @@ func NewClickhouseConnection(ctx context.Context, cfg Clickhouse) (*Connection,
 		sleepStep = 3000 * time.Millisecond
 	)
 
-	db = clickhouse.OpenDB(opt)
+	db = sql.OpenDB(&lifetimeConnector{
+		Connector: clickhouse.Connector(opt),
+		lifetime:  cfg.ConnMaxLifeTime,
+		jitter:    cfg.ConnMaxLifeTimeJitter,
+	})
 	db.SetMaxOpenConns(cfg.MaxOpenConn)
 	db.SetMaxIdleConns(cfg.MaxIdleConn)
 	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
-	db.SetConnMaxLifetime(cfg.ConnMaxLifeTime)
+	// общий лимит database/sql — страховка по верхней границе разброса, основной срок задаёт lifetimeConnector
+	db.SetConnMaxLifetime(cfg.ConnMaxLifeTime + max(cfg.ConnMaxLifeTimeJitter, 0))
 
 	tryContext, cancelContext := utils.TimeoutNoDeadline(ctx, 2*time.Second)
 	err := db.PingContext(tryContext)
@@ -84,12 +113,81 @@ func NewClickhouseConnection(ctx context.Context, cfg Clickhouse) (*Connection,
 	return &Connection{conn: db, cfg: cfg}, nil
 }
 
+// addrs адреса кластера: основной Host:Port (IPv6 в квадратных скобках) и Hosts
+func (cfg Clickhouse) addrs() []string {
+	return append([]string{net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}, cfg.Hosts...)
+}
+
 func (c *Connection) GetClickHouseConfig() Clickhouse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.cfg
 }
 
 func (c *Connection) Reconnect(ctx context.Context, cfg Clickhouse) error {
-	newConn, err := NewClickhouseConnection(ctx, cfg)
+	return c.reconnect(ctx, cfg, 0, nil)
+}
+
+// ReconnectWithBackoff перед переподключением ждёт случайную паузу в [0, maxJitter),
+// чтобы весь флот после общего сбоя ClickHouse не переподключался одновременно.
+func (c *Connection) ReconnectWithBackoff(ctx context.Context, cfg Clickhouse, maxJitter time.Duration) error {
+	return c.reconnect(ctx, cfg, maxJitter, nil)
+}
+
+// EnsureHealthy пингует текущий пул и переподключается, если пинг не прошёл.
+// Безопасно звать из многих горутин: при общем обрыве будет создан ровно один новый пул,
+// даже если часть горутин дойдёт до переподключения уже после того, как его сделала другая.
+func (c *Connection) EnsureHealthy(ctx context.Context) error {
+	db := c.GetDB()
+	pingCtx, cancel := utils.TimeoutNoDeadline(ctx, 2*time.Second)
+	err := db.PingContext(pingCtx)
+	cancel()
+	if err == nil {
+		return nil
+	}
+	return c.reconnect(ctx, c.GetClickHouseConfig(), 0, db)
+}
+
+// reconnect single-flight: если переподключение уже идёт, ждём его и возвращаем его результат.
+// broken — пул, на котором вызывающий увидел ошибку: если его уже заменили, переподключаться не нужно.
+// nil — переподключиться безусловно (явный Reconnect с новым конфигом).
+func (c *Connection) reconnect(ctx context.Context, cfg Clickhouse, maxJitter time.Duration, broken *sql.DB) error {
+	c.mu.Lock()
+	if broken != nil && c.conn != broken {
+		c.mu.Unlock()
+		return nil
+	}
+	if call := c.inflight; call != nil {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &reconnectCall{done: make(chan struct{})}
+	c.inflight = call
+	c.mu.Unlock()
+
+	call.err = c.doReconnect(ctx, cfg, maxJitter)
+
+	c.mu.Lock()
+	c.inflight = nil
+	c.mu.Unlock()
+	close(call.done)
+
+	return call.err
+}
+
+func (c *Connection) doReconnect(ctx context.Context, cfg Clickhouse, maxJitter time.Duration) error {
+	if maxJitter > 0 {
+		if err := utils.WaitOrCtx(ctx, time.Duration(rand.Int63n(int64(maxJitter)))); err != nil {
+			return fmt.Errorf("failed to reconnect to Clickhouse: %w", err)
+		}
+	}
+
+	newConn, err := openConnection(ctx, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to reconnect to Clickhouse: %w", err)
 	}
@@ -97,6 +195,7 @@ func (c *Connection) Reconnect(ctx context.Context, cfg Clickhouse) error {
 	c.mu.Lock()
 	old := c.conn
 	c.conn = newConn.conn
+	c.cfg = newConn.cfg
 	c.mu.Unlock()
 
 	err = old.Close()
@@ -113,11 +212,13 @@ func (c *Connection) Reconnect(ctx context.Context, cfg Clickhouse) error {
 }
 
 func (c *Connection) GetDB() *sql.DB {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.conn
 }
 
 func (c *Connection) disconnectFromDB(ctx context.Context) error {
-	if err := c.conn.Close(); err != nil {
+	if err := c.GetDB().Close(); err != nil {
 		logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
 			Msg:       "Failed to close Clickhouse connection",
 			Error:     err,
@@ -130,6 +231,11 @@ func (c *Connection) disconnectFromDB(ctx context.Context) error {
 	return nil
 }
 
+// Close закрывает пул (application.DBResource); sql.DB.Close сам дожидается запросов в работе.
+func (c *Connection) Close(ctx context.Context) error {
+	return c.disconnectFromDB(ctx)
+}
+
 func (c *Connection) Shutdown(ctx context.Context) func() {
 	return func() {
 		if err := c.disconnectFromDB(ctx); err != nil {
@@ -0,0 +1,113 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// okConnector пул, который всегда отвечает на Ping
+type okConnector struct{}
+
+func (okConnector) Connect(context.Context) (driver.Conn, error) { return okConn{}, nil }
+func (okConnector) Driver() driver.Driver                        { return nil }
+
+type okConn struct{}
+
+func (okConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (okConn) Close() error                        { return nil }
+func (okConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+// deadPool закрытый пул: Ping сразу возвращает ошибку
+func deadPool() *sql.DB {
+	db := sql.OpenDB(okConnector{})
+	_ = db.Close()
+	return db
+}
+
+// stubOpen подменяет openConnection и считает созданные пулы
+func stubOpen(t *testing.T, delay time.Duration) *atomic.Int64 {
+	t.Helper()
+	var opened atomic.Int64
+	prev := openConnection
+	openConnection = func(ctx context.Context, cfg Clickhouse) (*Connection, error) {
+		opened.Add(1)
+		time.Sleep(delay)
+		return &Connection{conn: sql.OpenDB(okConnector{}), cfg: cfg}, nil
+	}
+	t.Cleanup(func() { openConnection = prev })
+	return &opened
+}
+
+func TestEnsureHealthyCreatesOnePoolPerOutage(t *testing.T) {
+	opened := stubOpen(t, 0)
+	c := &Connection{conn: deadPool()}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := c.EnsureHealthy(context.Background()); err != nil {
+				t.Errorf("EnsureHealthy: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := opened.Load(); got != 1 {
+		t.Fatalf("opened %d pools, want 1", got)
+	}
+	if err := c.GetDB().Ping(); err != nil {
+		t.Fatalf("new pool is not healthy: %v", err)
+	}
+}
+
+func TestEnsureHealthySkipsAlreadyReplacedPool(t *testing.T) {
+	opened := stubOpen(t, 0)
+	dead := deadPool()
+	c := &Connection{conn: dead}
+
+	if err := c.reconnect(context.Background(), Clickhouse{}, 0, dead); err != nil {
+		t.Fatal(err)
+	}
+	// горутина пинговала старый пул и дошла до reconnect после того, как переподключение закончилось
+	if err := c.reconnect(context.Background(), Clickhouse{}, 0, dead); err != nil {
+		t.Fatal(err)
+	}
+	if got := opened.Load(); got != 1 {
+		t.Fatalf("opened %d pools, want 1", got)
+	}
+}
+
+func TestConcurrentReconnectCoalesces(t *testing.T) {
+	opened := stubOpen(t, 100*time.Millisecond)
+	c := &Connection{conn: deadPool()}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := c.Reconnect(context.Background(), Clickhouse{}); err != nil {
+				t.Errorf("Reconnect: %v", err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if got := opened.Load(); got != 1 {
+		t.Fatalf("opened %d pools, want 1", got)
+	}
+}
@@ -0,0 +1,69 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql/driver"
+	"math/rand"
+	"time"
+)
+
+// lifetimeConnector выдаёт каждому соединению собственный срок жизни в ConnMaxLifeTime ± jitter.
+// database/sql умеет только общий SetConnMaxLifetime, и соединения, открытые одной пачкой (старт, переподключение
+// за HAProxy), одной пачкой и истекают — получается всплеск переподключений.
+type lifetimeConnector struct {
+	driver.Connector
+	lifetime time.Duration
+	jitter   time.Duration
+}
+
+// stdConn методы соединения clickhouse-go, которые database/sql находит через приведение типов:
+// обёртка обязана их сохранить, иначе пул потеряет контексты, Ping и ResetSession.
+type stdConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.NamedValueChecker
+}
+
+type lifetimeConn struct {
+	stdConn
+	expiresAt time.Time
+}
+
+func (c *lifetimeConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	std, ok := conn.(stdConn)
+	if !ok {
+		return conn, nil
+	}
+	return &lifetimeConn{stdConn: std, expiresAt: time.Now().Add(jitteredLifetime(c.lifetime, c.jitter))}, nil
+}
+
+// jitteredLifetime случайный срок в [lifetime-jitter, lifetime+jitter]; jitter не больше половины lifetime.
+func jitteredLifetime(lifetime, jitter time.Duration) time.Duration {
+	jitter = min(jitter, lifetime/2)
+	if jitter <= 0 {
+		return lifetime
+	}
+	return lifetime - jitter + time.Duration(rand.Int63n(int64(2*jitter)+1))
+}
+
+// IsValid проверяется database/sql при возврате соединения в пул: истёкшее закрывается.
+func (c *lifetimeConn) IsValid() bool {
+	return time.Now().Before(c.expiresAt)
+}
+
+// ResetSession вызывается перед повторным использованием: истёкшее за время простоя соединение отбрасывается.
+func (c *lifetimeConn) ResetSession(ctx context.Context) error {
+	if !c.IsValid() {
+		return driver.ErrBadConn
+	}
+	return c.stdConn.ResetSession(ctx)
+}
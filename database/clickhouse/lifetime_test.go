@@ -0,0 +1,102 @@
+package clickhouse
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeConn соединение-заглушка: методы stdConn не вызываются, кроме ResetSession
+type fakeConn struct {
+	stdConn
+	resets int
+}
+
+func (c *fakeConn) ResetSession(context.Context) error {
+	c.resets++
+	return nil
+}
+
+type fakeConnector struct{ driver.Connector }
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{}, nil }
+
+func TestJitteredLifetimeVariesWithinBand(t *testing.T) {
+	const (
+		lifetime = time.Hour
+		jitter   = 6 * time.Minute
+	)
+	seen := map[time.Duration]bool{}
+	for range 1000 {
+		d := jitteredLifetime(lifetime, jitter)
+		if d < lifetime-jitter || d > lifetime+jitter {
+			t.Fatalf("lifetime %s outside %s ± %s", d, lifetime, jitter)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 100 {
+		t.Fatalf("only %d distinct lifetimes out of 1000", len(seen))
+	}
+}
+
+func TestJitteredLifetimeClampsAndDisables(t *testing.T) {
+	for range 1000 {
+		if d := jitteredLifetime(time.Hour, 2*time.Hour); d < 30*time.Minute || d > 90*time.Minute {
+			t.Fatalf("jitter not clamped to half the lifetime: %s", d)
+		}
+	}
+	for _, jitter := range []time.Duration{0, -time.Minute} {
+		if d := jitteredLifetime(time.Hour, jitter); d != time.Hour {
+			t.Fatalf("jitter %s: lifetime %s, want exact", jitter, d)
+		}
+	}
+}
+
+func TestLifetimeConnectorSpreadsExpiryAcrossConnections(t *testing.T) {
+	const (
+		lifetime = time.Hour
+		jitter   = 10 * time.Minute
+	)
+	c := &lifetimeConnector{Connector: fakeConnector{}, lifetime: lifetime, jitter: jitter}
+
+	start := time.Now()
+	seen := map[time.Time]bool{}
+	for range 50 {
+		conn, err := c.Connect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		lc, ok := conn.(*lifetimeConn)
+		if !ok {
+			t.Fatalf("connection %T not wrapped", conn)
+		}
+		if d := lc.expiresAt.Sub(start); d < lifetime-jitter || d > lifetime+jitter+time.Second {
+			t.Fatalf("expiry in %s, outside %s ± %s", d, lifetime, jitter)
+		}
+		seen[lc.expiresAt] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("all connections expire at the same moment")
+	}
+}
+
+func TestExpiredConnectionIsRejectedOnReuse(t *testing.T) {
+	inner := &fakeConn{}
+	live := &lifetimeConn{stdConn: inner, expiresAt: time.Now().Add(time.Minute)}
+	if !live.IsValid() {
+		t.Fatal("live connection reported invalid")
+	}
+	if err := live.ResetSession(context.Background()); err != nil || inner.resets != 1 {
+		t.Fatalf("live reset: err=%v, resets=%d", err, inner.resets)
+	}
+
+	expired := &lifetimeConn{stdConn: inner, expiresAt: time.Now().Add(-time.Second)}
+	if expired.IsValid() {
+		t.Fatal("expired connection reported valid")
+	}
+	if err := expired.ResetSession(context.Background()); !errors.Is(err, driver.ErrBadConn) || inner.resets != 1 {
+		t.Fatalf("expired reset: err=%v, resets=%d", err, inner.resets)
+	}
+}
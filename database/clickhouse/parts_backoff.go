@@ -0,0 +1,89 @@
+package clickhouse
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+const (
+	tooManyPartsCode = 252
+
+	DefaultPartsBackoffBase = 2 * time.Second
+	DefaultPartsBackoffMax  = time.Minute
+)
+
+// PartsBackoff адаптивная пауза для TOO_MANY_PARTS (252): каждый следующий 252 подряд по тому же ключу
+// (обычно таблица) удваивает паузу до max, успешная вставка сбрасывает счётчик.
+// Streak/CurrentWait можно использовать, чтобы заодно реже вставлять в перегруженную таблицу.
+type PartsBackoff struct {
+	mu     sync.Mutex
+	base   time.Duration
+	max    time.Duration
+	streak map[string]int
+}
+
+// NewPartsBackoff base <= 0 — DefaultPartsBackoffBase, max <= 0 — DefaultPartsBackoffMax.
+func NewPartsBackoff(base, max time.Duration) *PartsBackoff {
+	if base <= 0 {
+		base = DefaultPartsBackoffBase
+	}
+	if max <= 0 {
+		max = DefaultPartsBackoffMax
+	}
+	return &PartsBackoff{
+		base:   base,
+		max:    max,
+		streak: make(map[string]int),
+	}
+}
+
+// NeedWait как пакетный NeedWait, но для 252 по ключу key пауза растёт с каждым повтором.
+func (b *PartsBackoff) NeedWait(key string, err error) (bool, time.Duration, *ch.Exception) {
+	need, wait, exc := NeedWait(err)
+	var chErr *ch.Exception
+	if !errors.As(err, &chErr) || chErr.Code != tooManyPartsCode {
+		return need, wait, exc
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.streak[key]++
+	return true, b.wait(key), exc
+}
+
+// Success сбрасывает счётчик ключа после успешной вставки.
+func (b *PartsBackoff) Success(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.streak, key)
+}
+
+// Streak сколько 252 подряд получено по ключу.
+func (b *PartsBackoff) Streak(key string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.streak[key]
+}
+
+// CurrentWait текущая пауза для ключа, 0 — если 252 не было.
+func (b *PartsBackoff) CurrentWait(key string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.wait(key)
+}
+
+// wait вызывать под b.mu
+func (b *PartsBackoff) wait(key string) time.Duration {
+	n := b.streak[key]
+	if n == 0 {
+		return 0
+	}
+	wait := b.base
+	for i := 1; i < n && wait < b.max; i++ {
+		wait *= 2
+	}
+	return min(wait, b.max)
+}
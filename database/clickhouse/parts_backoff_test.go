@@ -0,0 +1,58 @@
+package clickhouse
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestPartsBackoffGrowsOnConsecutive252AndResetsOnSuccess(t *testing.T) {
+	b := NewPartsBackoff(time.Second, 5*time.Second)
+	tooManyParts := fmt.Errorf("insert events: %w", &ch.Exception{Code: tooManyPartsCode, Message: "Too many parts"})
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		need, wait, exc := b.NeedWait("events", tooManyParts)
+		if !need || wait != want || exc == nil || exc.Code != tooManyPartsCode {
+			t.Fatalf("252 #%d: need=%v wait=%s exc=%v, want wait %s", i+1, need, wait, exc, want)
+		}
+	}
+	if b.Streak("events") != 5 || b.CurrentWait("events") != 5*time.Second {
+		t.Fatalf("streak %d, current wait %s", b.Streak("events"), b.CurrentWait("events"))
+	}
+	// счётчик ведётся отдельно для каждой таблицы
+	if _, wait, _ := b.NeedWait("metrics", tooManyParts); wait != time.Second {
+		t.Fatalf("other key wait %s, want the base", wait)
+	}
+
+	b.Success("events")
+	if b.Streak("events") != 0 || b.CurrentWait("events") != 0 {
+		t.Fatalf("state not reset after success: streak %d, wait %s", b.Streak("events"), b.CurrentWait("events"))
+	}
+	if _, wait, _ := b.NeedWait("events", tooManyParts); wait != time.Second {
+		t.Fatalf("wait after reset %s, want the base", wait)
+	}
+	if b.Streak("metrics") != 1 {
+		t.Fatalf("success on events reset metrics: streak %d", b.Streak("metrics"))
+	}
+}
+
+func TestPartsBackoffLeavesOtherErrorsToNeedWait(t *testing.T) {
+	b := NewPartsBackoff(0, 0)
+
+	need, wait, _ := b.NeedWait("events", &ch.Exception{Code: 202})
+	if !need || wait != time.Second {
+		t.Fatalf("202: need=%v wait=%s, want the package NeedWait answer", need, wait)
+	}
+	if need, wait, _ = b.NeedWait("events", errors.New("connection refused")); need || wait != 0 {
+		t.Fatalf("unknown error: need=%v wait=%s", need, wait)
+	}
+	if b.Streak("events") != 0 {
+		t.Fatalf("non-252 errors counted in the streak: %d", b.Streak("events"))
+	}
+	if _, wait, _ = b.NeedWait("events", &ch.Exception{Code: tooManyPartsCode}); wait != DefaultPartsBackoffBase {
+		t.Fatalf("default base wait %s, want %s", wait, DefaultPartsBackoffBase)
+	}
+}
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	logger_wrapper "github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// Ожидаемая структура таблицы outbox:
+//
+//	CREATE TABLE outbox (
+//	    id           BIGSERIAL PRIMARY KEY,
+//	    topic        TEXT        NOT NULL,
+//	    key          TEXT        NOT NULL DEFAULT '',
+//	    payload      BYTEA       NOT NULL,
+//	    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+//	    published_at TIMESTAMPTZ
+//	);
+//	CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+const defaultOutboxTable = "outbox"
+
+type Event struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+type OutboxConfig struct {
+	Table        string
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// txBeginner то, что Outbox нужно от пула; *pgxpool.Pool его реализует, в тестах подменяется.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Outbox реализует transactional outbox: событие пишется в той же транзакции, что и бизнес-данные,
+// а Relay в фоне публикует неотправленные строки. Доставка at-least-once: строка помечается
+// отправленной только после успешного publish, поэтому при падении между ними событие уйдёт повторно.
+type Outbox struct {
+	pool  txBeginner
+	cfg   OutboxConfig
+	table string
+}
+
+func NewOutbox(conn *Connection, cfg OutboxConfig) *Outbox {
+	if cfg.Table == "" {
+		cfg.Table = defaultOutboxTable
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	return &Outbox{
+		pool:  conn.GetPool(),
+		cfg:   cfg,
+		table: pgx.Identifier{cfg.Table}.Sanitize(),
+	}
+}
+
+// WriteToOutbox пишет событие в outbox в рамках переданной транзакции.
+func (o *Outbox) WriteToOutbox(ctx context.Context, tx pgx.Tx, event Event) error {
+	_, err := tx.Exec(ctx,
+		fmt.Sprintf(`INSERT INTO %s (topic, key, payload) VALUES ($1, $2, $3)`, o.table),
+		event.Topic, event.Key, event.Payload,
+	)
+	if err != nil {
+		return fmt.Errorf("outbox write: %w", err)
+	}
+	return nil
+}
+
+// Relay запускает фоновую публикацию и возвращает функцию остановки, которая ждёт завершения текущего батча.
+// Несколько инстансов могут крутить Relay одновременно: строки берутся через FOR UPDATE SKIP LOCKED,
+// поэтому одну и ту же строку два релея параллельно не опубликуют.
+func (o *Outbox) Relay(ctx context.Context, publish func(Event) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	// WithoutCancel: горутина должна стартовать всегда, иначе wg.Done не вызовется и остановка зависнет;
+	// сам цикл следит за отменяемым ctx
+	utils.GoRecover(context.WithoutCancel(ctx), func(context.Context) {
+		defer wg.Done()
+		ticker := time.NewTicker(o.cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// выгребаем, пока есть полные батчи, чтобы не ждать тик на каждый
+				for {
+					n, err := o.relayBatch(ctx, publish)
+					if err != nil {
+						logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
+							Msg:       "Outbox relay batch failed",
+							Error:     err,
+							Component: "PostgresOutbox",
+							Method:    "Relay",
+							Args:      o.cfg.Table,
+						})
+						break
+					}
+					if n < o.cfg.BatchSize || ctx.Err() != nil {
+						break
+					}
+				}
+			}
+		}
+	})
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (o *Outbox) relayBatch(ctx context.Context, publish func(Event) error) (int, error) {
+	tx, err := o.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("outbox begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	rows, err := tx.Query(ctx,
+		fmt.Sprintf(`SELECT id, topic, key, payload, created_at FROM %s
+			WHERE published_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED`, o.table),
+		o.cfg.BatchSize,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("outbox select: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var e Event
+		err := row.Scan(&e.ID, &e.Topic, &e.Key, &e.Payload, &e.CreatedAt)
+		return e, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("outbox scan: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	published := make([]int64, 0, len(events))
+	var publishErr error
+	for _, e := range events {
+		if publishErr = publish(e); publishErr != nil {
+			break
+		}
+		published = append(published, e.ID)
+	}
+
+	if len(published) > 0 {
+		_, err = tx.Exec(ctx,
+			fmt.Sprintf(`UPDATE %s SET published_at = now() WHERE id = ANY($1)`, o.table),
+			published,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("outbox mark published: %w", err)
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("outbox commit: %w", err)
+	}
+	if publishErr != nil {
+		return len(published), fmt.Errorf("outbox publish event %d: %w", events[len(published)].ID, publishErr)
+	}
+	return len(published), nil
+}
@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB таблица outbox в памяти с построчными блокировками, как у FOR UPDATE SKIP LOCKED
+type fakeDB struct {
+	mu     sync.Mutex
+	nextID int64
+	rows   []*fakeRow
+}
+
+type fakeRow struct {
+	event     Event
+	published bool
+	lockedBy  *fakeTx
+}
+
+func (db *fakeDB) Begin(context.Context) (pgx.Tx, error) {
+	return &fakeTx{db: db}, nil
+}
+
+func (db *fakeDB) unpublished() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	n := 0
+	for _, r := range db.rows {
+		if !r.published {
+			n++
+		}
+	}
+	return n
+}
+
+// fakeTx понимает только запросы Outbox; остальные методы pgx.Tx не вызываются
+type fakeTx struct {
+	pgx.Tx
+	db        *fakeDB
+	inserts   []Event
+	published []int64
+	done      bool
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	switch {
+	case strings.HasPrefix(sql, "INSERT"):
+		tx.inserts = append(tx.inserts, Event{Topic: args[0].(string), Key: args[1].(string), Payload: args[2].([]byte)})
+	case strings.HasPrefix(sql, "UPDATE"):
+		tx.published = append(tx.published, args[0].([]int64)...)
+	default:
+		return pgconn.CommandTag{}, errors.New("unexpected exec: " + sql)
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (tx *fakeTx) Query(_ context.Context, _ string, args ...any) (pgx.Rows, error) {
+	limit := args[0].(int)
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	var events []Event
+	for _, r := range tx.db.rows {
+		if len(events) == limit {
+			break
+		}
+		if r.published || (r.lockedBy != nil && r.lockedBy != tx) {
+			continue
+		}
+		r.lockedBy = tx
+		events = append(events, r.event)
+	}
+	return &fakeRows{events: events, pos: -1}, nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	for _, e := range tx.inserts {
+		tx.db.nextID++
+		e.ID = tx.db.nextID
+		e.CreatedAt = time.Now()
+		tx.db.rows = append(tx.db.rows, &fakeRow{event: e})
+	}
+	for _, r := range tx.db.rows {
+		if slices.Contains(tx.published, r.event.ID) {
+			r.published = true
+		}
+	}
+	tx.release()
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.release()
+	return nil
+}
+
+func (tx *fakeTx) release() {
+	if tx.done {
+		return
+	}
+	tx.done = true
+	for _, r := range tx.db.rows {
+		if r.lockedBy == tx {
+			r.lockedBy = nil
+		}
+	}
+}
+
+type fakeRows struct {
+	pgx.Rows
+	events []Event
+	pos    int
+}
+
+func (r *fakeRows) Next() bool { r.pos++; return r.pos < len(r.events) }
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+func (r *fakeRows) Scan(dest ...any) error {
+	e := r.events[r.pos]
+	*dest[0].(*int64) = e.ID
+	*dest[1].(*string) = e.Topic
+	*dest[2].(*string) = e.Key
+	*dest[3].(*[]byte) = e.Payload
+	*dest[4].(*time.Time) = e.CreatedAt
+	return nil
+}
+
+func newTestOutbox(db *fakeDB, batch int) *Outbox {
+	return &Outbox{pool: db, cfg: OutboxConfig{Table: "outbox", BatchSize: batch, PollInterval: time.Millisecond}, table: `"outbox"`}
+}
+
+func seedOutbox(t *testing.T, db *fakeDB, n int) {
+	t.Helper()
+	o := newTestOutbox(db, 1)
+	tx, _ := db.Begin(context.Background())
+	for i := 0; i < n; i++ {
+		if err := o.WriteToOutbox(context.Background(), tx, Event{Topic: "t", Payload: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tx.Commit(context.Background())
+}
+
+func waitDrained(t *testing.T, db *fakeDB) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for db.unpublished() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d events left unpublished", db.unpublished())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOutboxRelayRetriesFailedPublish(t *testing.T) {
+	db := &fakeDB{}
+	seedOutbox(t, db, 5)
+
+	var mu sync.Mutex
+	counts := map[int64]int{}
+	failed := false
+	stop := newTestOutbox(db, 10).Relay(context.Background(), func(e Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.ID == 3 && !failed {
+			failed = true
+			return errors.New("broker unavailable")
+		}
+		counts[e.ID]++
+		return nil
+	})
+	waitDrained(t, db)
+	stop()
+
+	// событие, на котором publish упал, уходит повторно; успешно опубликованные до него — нет
+	for id := int64(1); id <= 5; id++ {
+		if counts[id] != 1 {
+			t.Fatalf("event %d published %d times, want 1 (counts %v)", id, counts[id], counts)
+		}
+	}
+	if !failed {
+		t.Fatal("publisher never failed")
+	}
+}
+
+func TestOutboxRelayStopsWithCancelledContext(t *testing.T) {
+	db := &fakeDB{}
+	seedOutbox(t, db, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stop := newTestOutbox(db, 10).Relay(ctx, func(Event) error {
+		t.Error("published with a cancelled context")
+		return nil
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop hung for a relay started with a cancelled context")
+	}
+}
+
+func TestOutboxConcurrentRelaysPublishOnce(t *testing.T) {
+	db := &fakeDB{}
+	seedOutbox(t, db, 200)
+
+	var mu sync.Mutex
+	counts := map[int64]int{}
+	publish := func(e Event) error {
+		mu.Lock()
+		counts[e.ID]++
+		mu.Unlock()
+		return nil
+	}
+
+	var stops []func()
+	for i := 0; i < 4; i++ {
+		stops = append(stops, newTestOutbox(db, 7).Relay(context.Background(), publish))
+	}
+	waitDrained(t, db)
+	for _, stop := range stops {
+		stop()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(counts) != 200 {
+		t.Fatalf("%d distinct events published, want 200", len(counts))
+	}
+	for id, n := range counts {
+		if n != 1 {
+			t.Fatalf("event %d published %d times", id, n)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	logger_wrapper "github.com/PavelAgarkov/service-pkg/logger"
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -27,6 +28,9 @@ type Configs struct {
 	HealthCheckPeriod     time.Duration
 	ConnectTimeout        time.Duration
 	MaxConnLifeTimeJitter time.Duration
+
+	// QueryTracer например NewQueryTracer() для логирования запросов со скрытыми параметрами. nil — без трассировки.
+	QueryTracer pgx.QueryTracer
 }
 
 type Connection struct {
@@ -101,6 +105,10 @@ func NewPostgresConnection(ctx context.Context, config Configs) *Connection {
 	// Помогает быстро понять, какой сервис или воркер держит соединение.
 	poolConfig.ConnConfig.RuntimeParams["application_name"] = config.ApplicationName
 
+	if config.QueryTracer != nil {
+		poolConfig.ConnConfig.Tracer = config.QueryTracer
+	}
+
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		logger.WriteFatalLog(ctx, &logger_wrapper.LogEntry{
@@ -119,6 +127,21 @@ func (r *Connection) Stop() {
 	r.pool.Close()
 }
 
+// Close закрывает пул, дожидаясь возврата занятых соединений, но не дольше ctx (application.DBResource).
+func (r *Connection) Close(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		r.pool.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (r *Connection) GetPool() *pgxpool.Pool {
 	return r.pool
 }
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	logger_wrapper "github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/jackc/pgx/v5"
+)
+
+const redacted = "[REDACTED]"
+
+// ArgRedactor вызывается для каждого параметра запроса перед записью в лог и возвращает то, что будет записано.
+type ArgRedactor func(index int, value any) any
+
+// RedactAll редактор по умолчанию: параметры в логах не видны вовсе.
+func RedactAll(int, any) any {
+	return redacted
+}
+
+// AllowArgs пишет в лог только параметры с перечисленными индексами (с нуля), остальные скрывает.
+func AllowArgs(indexes ...int) ArgRedactor {
+	allowed := make(map[int]struct{}, len(indexes))
+	for _, i := range indexes {
+		allowed[i] = struct{}{}
+	}
+	return func(index int, value any) any {
+		if _, ok := allowed[index]; ok {
+			return value
+		}
+		return redacted
+	}
+}
+
+// QueryTracer pgx.QueryTracer, который логирует запросы: успешные — debug, с ошибкой — error.
+// Параметры проходят через ArgRedactor, по умолчанию RedactAll.
+type QueryTracer struct {
+	redact ArgRedactor
+}
+
+type QueryTracerOption func(*QueryTracer)
+
+// WithArgRedactor включает запись параметров через redact, например AllowArgs(0, 2).
+func WithArgRedactor(redact ArgRedactor) QueryTracerOption {
+	return func(t *QueryTracer) {
+		if redact != nil {
+			t.redact = redact
+		}
+	}
+}
+
+func NewQueryTracer(opts ...QueryTracerOption) *QueryTracer {
+	t := &QueryTracer{redact: RedactAll}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+type traceQueryKey struct{}
+
+type traceQuery struct {
+	start time.Time
+	sql   string
+	args  []any
+}
+
+func (t *QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	args := make([]any, len(data.Args))
+	for i, v := range data.Args {
+		args[i] = t.redact(i, v)
+	}
+	return context.WithValue(ctx, traceQueryKey{}, &traceQuery{start: time.Now(), sql: data.SQL, args: args})
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(traceQueryKey{}).(*traceQuery)
+	if !ok {
+		return
+	}
+	entry := &logger_wrapper.LogEntry{
+		Msg:       q.sql,
+		Component: "PostgresConnection",
+		Method:    "Query",
+		Args:      q.args,
+		Start:     &q.start,
+		Error:     data.Err,
+		Fields:    map[string]any{"command_tag": data.CommandTag.String()},
+	}
+	if data.Err != nil {
+		logger.WriteErrorLog(ctx, entry)
+		return
+	}
+	logger.WriteDebugLog(ctx, entry)
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const loginSQL = "SELECT id FROM users WHERE login = $1 AND password = $2 AND token = $3"
+
+// traceLogin прогоняет запрос через tracer так же, как это делает pgx
+func traceLogin(tracer *QueryTracer, err error) {
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{
+		SQL:  loginSQL,
+		Args: []any{"alice", "s3cr3t-password", "tok-abc123"},
+	})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1"), Err: err})
+}
+
+func TestQueryTracerRedactsAllArgsByDefault(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	traceLogin(NewQueryTracer(), nil)
+
+	out := logs.String()
+	for _, secret := range []string{"alice", "s3cr3t-password", "tok-abc123"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("argument %q leaked into the log: %s", secret, out)
+		}
+	}
+	entries := logs.WithMessage(loginSQL)
+	if len(entries) != 1 || entries[0]["level"] != "debug" {
+		t.Fatalf("entries %v, want one debug record", entries)
+	}
+	args, _ := entries[0]["args"].([]any)
+	if len(args) != 3 || args[0] != redacted || args[2] != redacted {
+		t.Fatalf("args %v, want every argument redacted", entries[0]["args"])
+	}
+}
+
+func TestQueryTracerAllowArgs(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	traceLogin(NewQueryTracer(WithArgRedactor(AllowArgs(0))), errors.New("deadlock detected"))
+
+	out := logs.String()
+	if strings.Contains(out, "s3cr3t-password") || strings.Contains(out, "tok-abc123") {
+		t.Fatalf("denied arguments leaked into the log: %s", out)
+	}
+	entries := logs.WithMessage(loginSQL)
+	if len(entries) != 1 || entries[0]["level"] != "error" || entries[0]["error"] != "deadlock detected" {
+		t.Fatalf("entries %v, want one error record", entries)
+	}
+	if args, _ := entries[0]["args"].([]any); len(args) != 3 || args[0] != "alice" || args[1] != redacted {
+		t.Fatalf("args %v, want only the first argument visible", entries[0]["args"])
+	}
+}
+
+func TestQueryTracerCustomRedactor(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	mask := func(_ int, v any) any {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, "tok-") {
+			return "tok-***"
+		}
+		return v
+	}
+	traceLogin(NewQueryTracer(WithArgRedactor(mask)), nil)
+
+	if out := logs.String(); strings.Contains(out, "tok-abc123") || !strings.Contains(out, "tok-***") {
+		t.Fatalf("custom redactor not applied: %s", out)
+	}
+}
@@ -0,0 +1,154 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+// DefaultMaxRetryAfter верхняя граница ожидания по Retry-After, чтобы сервер не мог подвесить клиента надолго
+const DefaultMaxRetryAfter = time.Minute
+
+type Client struct {
+	http          *http.Client
+	policy        utils.RetryPolicy
+	maxRetryAfter time.Duration
+}
+
+type Option func(*Client)
+
+// WithMaxRetryAfter ограничивает ожидание по заголовку Retry-After, по умолчанию DefaultMaxRetryAfter.
+func WithMaxRetryAfter(d time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetryAfter = d
+	}
+}
+
+// New клиент с повторами по policy. Повторяются сетевые ошибки и ответы 429, 502, 503, 504;
+// на 429 и 503 с Retry-After ждём указанное время, иначе — экспоненциальная пауза policy.
+// policy.Retryable, если задан, дополнительно фильтрует ошибки. httpClient == nil — http.DefaultClient.
+func New(httpClient *http.Client, policy utils.RetryPolicy, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{
+		http:          httpClient,
+		policy:        policy,
+		maxRetryAfter: DefaultMaxRetryAfter,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// StatusError повторяемый статус ответа промежуточной попытки; RetryAfter берётся из заголовка Retry-After.
+type StatusError struct {
+	StatusCode int
+	retryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.StatusCode)
+}
+
+func (e *StatusError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// Do выполняет запрос с повторами, отмена через req.Context(). Если все попытки получили повторяемый статус,
+// возвращается ответ последней попытки без ошибки, как у http.Client. Запрос с телом повторяется,
+// только если задан req.GetBody (его выставляет http.NewRequest для bytes/strings-ридеров), иначе одна попытка.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	policy := c.policy
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		policy.Attempts = 1
+	}
+	attempts := max(policy.Attempts, 1)
+	retryable := policy.Retryable
+	policy.Retryable = func(err error) bool {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		return retryable == nil || retryable(err)
+	}
+
+	var (
+		resp    *http.Response
+		attempt int
+	)
+	err := utils.Retry(req.Context(), policy, func(ctx context.Context) error {
+		attempt++
+		r := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		var err error
+		resp, err = c.http.Do(r)
+		if err != nil {
+			return err
+		}
+		if !retryableStatus(resp.StatusCode) || attempt >= attempts {
+			return nil
+		}
+
+		statusErr := &StatusError{StatusCode: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			statusErr.retryAfter = min(parseRetryAfter(resp.Header.Get("Retry-After")), c.maxRetryAfter)
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+		resp = nil
+
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("%s %s returned %d, retrying", req.Method, req.URL.Redacted(), statusErr.StatusCode),
+			Component: "httpclient",
+			Method:    "Do",
+			Args:      fmt.Sprintf("attempt: %d, retry_after: %s", attempt, statusErr.retryAfter),
+		})
+		return statusErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter поддерживает обе формы заголовка: секунды и HTTP-дату. Некорректное значение — 0.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
@@ -0,0 +1,173 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+// stubServer отвечает по очереди статусами из responses, дальше — 200. Запоминает время и тело запросов.
+type stubServer struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	hits      []time.Time
+	bodies    []string
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.hits = append(s.hits, time.Now())
+	s.bodies = append(s.bodies, string(body))
+	var respond func(w http.ResponseWriter)
+	if len(s.responses) > 0 {
+		respond, s.responses = s.responses[0], s.responses[1:]
+	}
+	s.mu.Unlock()
+	if respond == nil {
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+	respond(w)
+}
+
+func status(code int, retryAfter string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func startStub(t *testing.T, responses ...func(w http.ResponseWriter)) (*stubServer, string) {
+	t.Helper()
+	stub := &stubServer{responses: responses}
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	return stub, srv.URL
+}
+
+func TestDoWaitsRetryAfterOn429(t *testing.T) {
+	stub, url := startStub(t, status(http.StatusTooManyRequests, "1"))
+	c := New(nil, utils.RetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.hits) != 2 {
+		t.Fatalf("%d requests, want 2", len(stub.hits))
+	}
+	if gap := stub.hits[1].Sub(stub.hits[0]); gap < time.Second {
+		t.Fatalf("retried after %s, Retry-After is 1s", gap)
+	}
+}
+
+func TestDoCapsRetryAfterAndUsesBackoffWithoutHeader(t *testing.T) {
+	stub, url := startStub(t,
+		status(http.StatusServiceUnavailable, "3600"),
+		status(http.StatusBadGateway, ""),
+	)
+	c := New(nil, utils.RetryPolicy{Attempts: 3, Backoff: 20 * time.Millisecond}, WithMaxRetryAfter(30*time.Millisecond))
+
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("took %s, want the capped Retry-After plus one backoff", elapsed)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.hits) != 3 {
+		t.Fatalf("%d requests, want 3", len(stub.hits))
+	}
+}
+
+func TestDoReturnsLastResponseWhenAttemptsExhausted(t *testing.T) {
+	_, url := startStub(t, status(http.StatusTooManyRequests, ""), status(http.StatusTooManyRequests, ""))
+	c := New(nil, utils.RetryPolicy{Attempts: 2, Backoff: time.Millisecond})
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want the last 429", resp.StatusCode)
+	}
+}
+
+func TestDoReplaysBody(t *testing.T) {
+	stub, url := startStub(t, status(http.StatusServiceUnavailable, ""))
+	c := New(nil, utils.RetryPolicy{Attempts: 2, Backoff: time.Millisecond})
+
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"id":1}`))
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.bodies) != 2 || stub.bodies[1] != `{"id":1}` {
+		t.Fatalf("bodies %q, want the body sent on both attempts", stub.bodies)
+	}
+}
+
+func TestDoStopsOnContextCancel(t *testing.T) {
+	stub, url := startStub(t, status(http.StatusTooManyRequests, "60"))
+	c := New(nil, utils.RetryPolicy{Attempts: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	start := time.Now()
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Do returned after %s, the context expired after 50ms", elapsed)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.hits) != 1 {
+		t.Fatalf("%d requests, want 1", len(stub.hits))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	for in, check := range map[string]func(time.Duration) bool{
+		"":     func(d time.Duration) bool { return d == 0 },
+		"5":    func(d time.Duration) bool { return d == 5*time.Second },
+		"-1":   func(d time.Duration) bool { return d == 0 },
+		"soon": func(d time.Duration) bool { return d == 0 },
+		date:   func(d time.Duration) bool { return d > 59*time.Minute && d <= time.Hour },
+	} {
+		if got := parseRetryAfter(in); !check(got) {
+			t.Errorf("parseRetryAfter(%q) = %s", in, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package logtest перехват глобального логгера в тестах.
+package logtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Init инициализация глобального логгера, обычно zap_engine.InitLogger. Передаётся вызывающим,
+// чтобы тесты самого zap_engine могли использовать пакет без цикла импортов.
+type Init func(level zapcore.Level, cloud bool, cfg *zapcore.EncoderConfig, ws zapcore.WriteSyncer, option ...zap.Option) error
+
+// Buffer собирает JSON-записи глобального логгера
+type Buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *Buffer) Sync() error { return nil }
+
+func (b *Buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Lines строки консольного вывода
+func (b *Buffer) Lines() []string {
+	s := strings.TrimRight(b.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func (b *Buffer) Entries() []map[string]any {
+	var out []map[string]any
+	sc := bufio.NewScanner(strings.NewReader(b.String()))
+	for sc.Scan() {
+		var e map[string]any
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (b *Buffer) WithMessage(msg string) []map[string]any {
+	var out []map[string]any
+	for _, e := range b.Entries() {
+		if e["message"] == msg {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+type options struct {
+	level   zapcore.Level
+	console bool
+	zapOpts []zap.Option
+}
+
+type Option func(*options)
+
+// WithLevel уровень логгера на время теста, по умолчанию Debug
+func WithLevel(level zapcore.Level) Option {
+	return func(o *options) { o.level = level }
+}
+
+// Console консольный режим вместо cloud
+func Console() Option {
+	return func(o *options) { o.console = true }
+}
+
+// WithZapOptions опции, передаваемые в Init, например zap_engine.WithInstanceFields
+func WithZapOptions(opts ...zap.Option) Option {
+	return func(o *options) { o.zapOpts = append(o.zapOpts, opts...) }
+}
+
+// Capture переключает глобальный логгер в cloud-режим с записью в буфер до конца теста
+func Capture(t testing.TB, init Init, opts ...Option) *Buffer {
+	t.Helper()
+	o := options{level: zapcore.DebugLevel}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	b := &Buffer{}
+	if err := init(o.level, !o.console, nil, b, o.zapOpts...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = init(zapcore.DebugLevel, true, nil, zapcore.AddSync(io.Discard)) })
+	return b
+}
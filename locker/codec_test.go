@@ -0,0 +1,47 @@
+package locker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type codecPayload struct {
+	Status  int
+	Headers map[string][]string
+	Body    []byte
+	Stored  time.Time
+}
+
+func TestCodecsRoundTripStruct(t *testing.T) {
+	in := codecPayload{
+		Status:  201,
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+		Body:    []byte(`{"id":7}`),
+		Stored:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	for name, codec := range map[string]Codec{"json": JSONCodec{}, "gob": GobCodec{}} {
+		t.Run(name, func(t *testing.T) {
+			data, err := codec.Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out codecPayload
+			if err = codec.Unmarshal(data, &out); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(in, out) {
+				t.Fatalf("round trip %+v, want %+v", out, in)
+			}
+		})
+	}
+}
+
+func TestCodecsRejectGarbage(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSONCodec{}, "gob": GobCodec{}} {
+		var out codecPayload
+		if err := codec.Unmarshal([]byte("not encoded"), &out); err == nil {
+			t.Fatalf("%s: Unmarshal accepted garbage", name)
+		}
+	}
+}
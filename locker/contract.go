@@ -15,5 +15,56 @@ type (
 		Lock(ctx context.Context, key, value string, expiration time.Duration) (bool, error)
 		Unlock(ctx context.Context, key, value string) (bool, error)
 		ExtendLockTTL(ctx context.Context, key, value string, expiration time.Duration) (bool, error)
+		// AcquireOrExtend атомарно берёт свободную блокировку или продлевает свою.
+		// true — после вызова блокировка наша.
+		AcquireOrExtend(ctx context.Context, key, value string, expiration time.Duration) (bool, error)
+		// LockWithRetry повторяет Lock каждые p.RetryInterval, пока не возьмёт блокировку или не истечёт p.Deadline.
+		// По истечении Deadline — false без ошибки, при отмене ctx — ошибка ctx.
+		LockWithRetry(ctx context.Context, p Parameters) (bool, error)
+	}
+
+	// BatchExtender продлевает несколько блокировок за один запрос. Реализуют RedisLocker и MemoryLocker,
+	// LeaseManager использует его, если Locker его поддерживает.
+	BatchExtender interface {
+		ExtendMany(ctx context.Context, leases []Lease, expiration time.Duration) ([]bool, error)
 	}
 )
+
+// Lease блокировка key, которой владеет value.
+type Lease struct {
+	Key, Value string
+}
+
+// DefaultLockRetryInterval пауза между попытками LockWithRetry, если RetryInterval не задан
+const DefaultLockRetryInterval = 100 * time.Millisecond
+
+// lockWithRetry общая реализация LockWithRetry поверх Lock конкретного локера.
+// Deadline <= 0 — ждать, пока не отменят ctx.
+func lockWithRetry(ctx context.Context, lock func(ctx context.Context, key, value string, expiration time.Duration) (bool, error), p Parameters) (bool, error) {
+	interval := p.RetryInterval
+	if interval <= 0 {
+		interval = DefaultLockRetryInterval
+	}
+	var deadline <-chan time.Time
+	if p.Deadline > 0 {
+		timer := time.NewTimer(p.Deadline)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := lock(ctx, p.Key, p.Value, p.Expiration)
+		if err != nil || ok {
+			return ok, err
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-deadline:
+			return false, nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+var (
+	ErrLockNotAcquired = errors.New("lock is held by another owner")
+	ErrLockLost        = errors.New("lock ownership lost")
+)
+
+// LockGuard держит блокировку и продлевает её в фоне, пока не вызовут Release или не отменят ctx AcquireGuard.
+// Если блокировка потеряна (занята другим владельцем или не продлевалась дольше expiration), в Lost
+// приходит ошибка, продление прекращается, и работу под блокировкой нужно прервать.
+type LockGuard struct {
+	locker     Locker
+	key, value string
+
+	cancel      context.CancelFunc
+	done        chan struct{}
+	lost        chan error
+	releaseOnce sync.Once
+	releaseErr  error
+}
+
+// AcquireGuard берёт блокировку l и продлевает её каждые renewEvery, см. LockGuard.
+// Работает с любым Locker: это Lock плюс фоновое ExtendLockTTL. Если блокировка занята — ErrLockNotAcquired.
+func AcquireGuard(ctx context.Context, l Locker, key, value string, expiration, renewEvery time.Duration) (*LockGuard, error) {
+	if renewEvery <= 0 || renewEvery >= expiration {
+		return nil, fmt.Errorf("renewEvery %s must be positive and less than expiration %s", renewEvery, expiration)
+	}
+	ok, err := l.Lock(ctx, key, value, expiration)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLockNotAcquired
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	g := &LockGuard{
+		locker: l,
+		key:    key,
+		value:  value,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		lost:   make(chan error, 1),
+	}
+
+	// WithoutCancel: горутина должна стартовать всегда, иначе done не закроется и Release зависнет
+	utils.GoRecover(context.WithoutCancel(ctx), func(context.Context) {
+		defer close(g.done)
+		ticker := time.NewTicker(renewEvery)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			ok, err := l.ExtendLockTTL(ctx, key, value, expiration)
+			switch {
+			case err == nil && ok:
+				renewed = time.Now()
+				continue
+			case err == nil:
+				g.lost <- ErrLockLost
+				return
+			case ctx.Err() != nil:
+				return
+			}
+
+			// сетевая ошибка: блокировка ещё может быть нашей, пока не истёк её TTL
+			logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+				Msg:       "failed to renew lock",
+				Component: "locker",
+				Method:    "LockGuard",
+				Args:      key,
+				Error:     err,
+			})
+			if time.Since(renewed) >= expiration {
+				g.lost <- fmt.Errorf("%w: not renewed for %s: %v", ErrLockLost, expiration, err)
+				return
+			}
+		}
+	})
+
+	return g, nil
+}
+
+// Lost получает ошибку, если блокировка потеряна. Канал не закрывается.
+func (g *LockGuard) Lost() <-chan error {
+	return g.lost
+}
+
+// Release останавливает продление и снимает блокировку. Повторные вызовы возвращают результат первого.
+func (g *LockGuard) Release(ctx context.Context) error {
+	g.releaseOnce.Do(func() {
+		g.cancel()
+		<-g.done
+		if _, err := g.locker.Unlock(ctx, g.key, g.value); err != nil {
+			g.releaseErr = err
+		}
+	})
+	return g.releaseErr
+}
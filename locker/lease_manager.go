@@ -0,0 +1,182 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+// LeaseManager держит набор блокировок и продлевает их на общем тике: если Locker реализует
+// BatchExtender — одним запросом на все, иначе по одной через ExtendLockTTL.
+// Потерянная блокировка убирается из набора, и вызывается её onLost.
+type LeaseManager struct {
+	locker   Locker
+	ttl      time.Duration
+	interval time.Duration
+
+	mu     sync.Mutex
+	leases map[string]*managedLease
+}
+
+type managedLease struct {
+	value  string
+	onLost func()
+}
+
+// NewLeaseManager ttl — на сколько продлевать, interval — как часто; interval должен быть заметно меньше ttl.
+func NewLeaseManager(locker Locker, ttl, interval time.Duration) *LeaseManager {
+	return &LeaseManager{
+		locker:   locker,
+		ttl:      ttl,
+		interval: interval,
+		leases:   make(map[string]*managedLease),
+	}
+}
+
+// Acquire берёт блокировку key и ставит её на продление. onLost может быть nil.
+func (m *LeaseManager) Acquire(ctx context.Context, key, value string, onLost func()) (bool, error) {
+	ok, err := m.locker.Lock(ctx, key, value, m.ttl)
+	if err != nil || !ok {
+		return false, err
+	}
+	m.Add(key, value, onLost)
+	return true, nil
+}
+
+// Add ставит на продление уже взятую блокировку.
+func (m *LeaseManager) Add(key, value string, onLost func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leases[key] = &managedLease{value: value, onLost: onLost}
+}
+
+// Release снимает блокировку и убирает её из продления.
+func (m *LeaseManager) Release(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	l, ok := m.leases[key]
+	delete(m.leases, key)
+	m.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return m.locker.Unlock(ctx, key, l.value)
+}
+
+// Len число блокировок на продлении.
+func (m *LeaseManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.leases)
+}
+
+// Start запускает продление и возвращает функцию остановки, которая дожидается выхода горутины.
+// Блокировки при остановке не снимаются. Вызывать один раз.
+func (m *LeaseManager) Start(ctx context.Context) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	// WithoutCancel: горутина должна стартовать всегда, иначе done не закроется
+	utils.GoRecover(context.WithoutCancel(ctx), func(context.Context) {
+		defer close(done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.Renew(ctx)
+			}
+		}
+	})
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// Renew продлевает все блокировки сразу. Вызывается Start на каждом тике, доступен для ручного продления.
+func (m *LeaseManager) Renew(ctx context.Context) {
+	m.mu.Lock()
+	leases := make([]Lease, 0, len(m.leases))
+	for key, l := range m.leases {
+		leases = append(leases, Lease{Key: key, Value: l.value})
+	}
+	m.mu.Unlock()
+	if len(leases) == 0 {
+		return
+	}
+
+	ok, err := m.extend(ctx, leases)
+	if err != nil {
+		// сетевая ошибка не значит потерю: блокировки ещё живы до ttl, попробуем на следующем тике
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       "failed to renew leases",
+			Component: "LeaseManager",
+			Method:    "Renew",
+			Args:      fmt.Sprintf("leases: %d", len(leases)),
+			Error:     err,
+		})
+		return
+	}
+
+	for i, l := range leases {
+		if ok[i] {
+			continue
+		}
+		m.mu.Lock()
+		cur, found := m.leases[l.Key]
+		// за время запроса блокировку могли отпустить или перевзять с другим value
+		if found && cur.value == l.Value {
+			delete(m.leases, l.Key)
+		} else {
+			found = false
+		}
+		m.mu.Unlock()
+		if !found {
+			continue
+		}
+
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       "lease lost",
+			Component: "LeaseManager",
+			Method:    "Renew",
+			Args:      l.Key,
+		})
+		if cur.onLost != nil {
+			cur.onLost()
+		}
+	}
+}
+
+// extend при отсутствии BatchExtender продлевает по одной; ошибка одной не мешает остальным,
+// такая блокировка просто не считается потерянной на этом тике.
+func (m *LeaseManager) extend(ctx context.Context, leases []Lease) ([]bool, error) {
+	if batch, ok := m.locker.(BatchExtender); ok {
+		return batch.ExtendMany(ctx, leases, m.ttl)
+	}
+
+	ok := make([]bool, len(leases))
+	for i, l := range leases {
+		extended, err := m.locker.ExtendLockTTL(ctx, l.Key, l.Value, m.ttl)
+		if err != nil {
+			logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+				Msg:       "failed to renew lease",
+				Component: "LeaseManager",
+				Method:    "Renew",
+				Args:      l.Key,
+				Error:     err,
+			})
+			extended = true
+		}
+		ok[i] = extended
+	}
+	return ok, nil
+}
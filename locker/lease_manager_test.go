@@ -0,0 +1,120 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestLeaseManagerRenewsLeasesInOneCall(t *testing.T) {
+	srv, addr := startScriptRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	const n = 5
+	m := NewLeaseManager(NewLocker(client), 3*time.Second, time.Second)
+	srv.mu.Lock()
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("job-%d", i)
+		srv.keys[lockKeyPrefix+key] = "me"
+		m.Add(key, "me", nil)
+	}
+	// эту блокировку уже перехватил другой инстанс
+	srv.keys[lockKeyPrefix+"job-2"] = "other"
+	srv.mu.Unlock()
+	var lost atomic.Int32
+	m.Add("job-2", "me", func() { lost.Add(1) })
+
+	m.Renew(context.Background())
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.evals != 1 {
+		t.Fatalf("%d redis calls for %d leases, want 1", srv.evals, n)
+	}
+	for i := 0; i < n; i++ {
+		key := lockKeyPrefix + fmt.Sprintf("job-%d", i)
+		want := "3000"
+		if i == 2 {
+			want = ""
+		}
+		if got := srv.ttls[key]; got != want {
+			t.Fatalf("%s ttl %q, want %q", key, got, want)
+		}
+	}
+	if lost.Load() != 1 || m.Len() != n-1 {
+		t.Fatalf("onLost called %d times, %d leases left; want the foreign one dropped", lost.Load(), m.Len())
+	}
+}
+
+// singleExtendLocker прячет ExtendMany у MemoryLocker, чтобы LeaseManager продлевал по одной,
+// и ломает продление ключа broken
+type singleExtendLocker struct {
+	mem   *MemoryLocker
+	calls atomic.Int32
+	Locker
+}
+
+func (l *singleExtendLocker) ExtendLockTTL(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	l.calls.Add(1)
+	if key == "broken" {
+		return false, errors.New("connection reset")
+	}
+	return l.mem.ExtendLockTTL(ctx, key, value, ttl)
+}
+
+func TestLeaseManagerFailingExtendDoesNotBlockOthers(t *testing.T) {
+	ctx := context.Background()
+	clock := NewManualClock(time.Unix(0, 0))
+	mem := NewMemoryLocker(WithClock(clock.Now))
+	l := &singleExtendLocker{mem: mem, Locker: mem}
+	m := NewLeaseManager(l, time.Second, 500*time.Millisecond)
+
+	var lost atomic.Int32
+	for _, key := range []string{"a", "broken", "b"} {
+		if ok, err := m.Acquire(ctx, key, "me", func() { lost.Add(1) }); !ok || err != nil {
+			t.Fatalf("Acquire(%s): ok %v, err %v", key, ok, err)
+		}
+	}
+
+	clock.Advance(800 * time.Millisecond)
+	m.Renew(ctx)
+	if l.calls.Load() != 3 {
+		t.Fatalf("%d extend calls, want one per lease", l.calls.Load())
+	}
+	// ошибка продления не считается потерей: блокировка остаётся в наборе до следующего тика
+	if lost.Load() != 0 || m.Len() != 3 {
+		t.Fatalf("onLost called %d times, %d leases left after a transient error", lost.Load(), m.Len())
+	}
+
+	clock.Advance(800 * time.Millisecond)
+	for key, wantHeld := range map[string]bool{"a": true, "b": true, "broken": false} {
+		took, err := mem.Lock(ctx, key, "other", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if took == wantHeld {
+			t.Fatalf("%s: other owner took it %v, want the lease held %v", key, took, wantHeld)
+		}
+	}
+}
+
+func TestLeaseManagerStartRenewsOnTick(t *testing.T) {
+	mem := NewMemoryLocker()
+	m := NewLeaseManager(mem, 100*time.Millisecond, 20*time.Millisecond)
+	if ok, err := m.Acquire(context.Background(), "key", "me", nil); !ok || err != nil {
+		t.Fatalf("Acquire: ok %v, err %v", ok, err)
+	}
+	stop := m.Start(context.Background())
+
+	time.Sleep(300 * time.Millisecond)
+	if ok, _ := mem.Lock(context.Background(), "key", "other", time.Second); ok {
+		t.Fatal("lease expired while the manager was renewing it")
+	}
+	stop()
+}
@@ -0,0 +1,191 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+type memoryEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+// MemoryLocker in-memory реализация Locker с той же семантикой, что и Lua-скрипты RedisLocker:
+// SET NX PX на Lock, проверка владельца на Unlock/ExtendLockTTL, истёкший ключ считается отсутствующим.
+// Предназначен для тестов и однопроцессных сценариев.
+type MemoryLocker struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type MemoryLockerOption func(*MemoryLocker)
+
+// WithClock подменяет источник времени, например ManualClock.Now в тестах.
+func WithClock(now func() time.Time) MemoryLockerOption {
+	return func(locker *MemoryLocker) {
+		locker.now = now
+	}
+}
+
+func NewMemoryLocker(opts ...MemoryLockerOption) *MemoryLocker {
+	locker := &MemoryLocker{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(locker)
+	}
+	return locker
+}
+
+func (locker *MemoryLocker) Lock(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+
+	if _, ok := locker.get(key); ok {
+		return false, nil
+	}
+	locker.entries[key] = memoryEntry{value: value, expireAt: locker.now().Add(expiration)}
+	return true, nil
+}
+
+func (locker *MemoryLocker) Unlock(ctx context.Context, key, value string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+
+	e, ok := locker.get(key)
+	if !ok || e.value != value {
+		return false, nil
+	}
+	delete(locker.entries, key)
+	return true, nil
+}
+
+func (locker *MemoryLocker) ExtendLockTTL(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+
+	e, ok := locker.get(key)
+	if !ok || e.value != value {
+		return false, nil
+	}
+	e.expireAt = locker.now().Add(expiration)
+	locker.entries[key] = e
+	return true, nil
+}
+
+func (locker *MemoryLocker) LockWithRetry(ctx context.Context, p Parameters) (bool, error) {
+	return lockWithRetry(ctx, locker.Lock, p)
+}
+
+func (locker *MemoryLocker) AcquireOrExtend(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+
+	if e, ok := locker.get(key); ok && e.value != value {
+		return false, nil
+	}
+	locker.entries[key] = memoryEntry{value: value, expireAt: locker.now().Add(expiration)}
+	return true, nil
+}
+
+func (locker *MemoryLocker) ExtendMany(ctx context.Context, leases []Lease, expiration time.Duration) ([]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+
+	ok := make([]bool, len(leases))
+	for i, l := range leases {
+		e, found := locker.get(l.Key)
+		if !found || e.value != l.Value {
+			continue
+		}
+		e.expireAt = locker.now().Add(expiration)
+		locker.entries[l.Key] = e
+		ok[i] = true
+	}
+	return ok, nil
+}
+
+// get возвращает живую запись, истёкшую удаляет. Вызывать под locker.mu.
+func (locker *MemoryLocker) get(key string) (memoryEntry, bool) {
+	e, ok := locker.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !locker.now().Before(e.expireAt) {
+		delete(locker.entries, key)
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+// Sweep удаляет все истёкшие ключи. Ключи и так истекают лениво при обращении,
+// Sweep нужен, чтобы не копить мусор от ключей, к которым больше не обращаются.
+func (locker *MemoryLocker) Sweep() {
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+
+	now := locker.now()
+	for key, e := range locker.entries {
+		if !now.Before(e.expireAt) {
+			delete(locker.entries, key)
+		}
+	}
+}
+
+// StartSweeper запускает фоновый Sweep каждые interval до отмены ctx.
+func (locker *MemoryLocker) StartSweeper(ctx context.Context, interval time.Duration) {
+	utils.GoRecover(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				locker.Sweep()
+			}
+		}
+	})
+}
+
+// ManualClock управляемые часы для детерминированной проверки TTL в тестах.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
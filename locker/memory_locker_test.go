@@ -0,0 +1,186 @@
+package locker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryLockerContendedLock(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	var wins atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(owner int) {
+			defer wg.Done()
+			ok, err := l.Lock(ctx, "key", string(rune('a'+owner)), time.Minute)
+			if err != nil {
+				t.Error(err)
+			}
+			if ok {
+				wins.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := wins.Load(); got != 1 {
+		t.Fatalf("%d owners acquired the lock, want 1", got)
+	}
+}
+
+func TestMemoryLockerExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewMemoryLocker(WithClock(clock.Now))
+	ctx := context.Background()
+
+	if ok, _ := l.Lock(ctx, "key", "a", time.Second); !ok {
+		t.Fatal("first Lock failed")
+	}
+	clock.Advance(999 * time.Millisecond)
+	if ok, _ := l.Lock(ctx, "key", "b", time.Second); ok {
+		t.Fatal("lock taken before TTL expired")
+	}
+	clock.Advance(time.Millisecond)
+	if ok, _ := l.Lock(ctx, "key", "b", time.Second); !ok {
+		t.Fatal("lock is not free after TTL")
+	}
+	if ok, _ := l.ExtendLockTTL(ctx, "key", "a", time.Second); ok {
+		t.Fatal("expired owner extended the lock")
+	}
+}
+
+func TestMemoryLockerWrongOwnerUnlock(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx := context.Background()
+
+	if ok, _ := l.Lock(ctx, "key", "a", time.Minute); !ok {
+		t.Fatal("Lock failed")
+	}
+	if ok, _ := l.Unlock(ctx, "key", "b"); ok {
+		t.Fatal("wrong owner unlocked the lock")
+	}
+	if ok, _ := l.Lock(ctx, "key", "b", time.Minute); ok {
+		t.Fatal("lock was released by wrong-owner Unlock")
+	}
+	if ok, _ := l.Unlock(ctx, "key", "a"); !ok {
+		t.Fatal("owner failed to unlock")
+	}
+}
+
+func TestMemoryLockerSemantics(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewMemoryLocker(WithClock(clock.Now))
+	ctx := context.Background()
+
+	if ok, _ := l.ExtendLockTTL(ctx, "key", "a", time.Second); ok {
+		t.Fatal("extended an absent lock")
+	}
+	if ok, _ := l.Unlock(ctx, "key", "a"); ok {
+		t.Fatal("unlocked an absent lock")
+	}
+	if ok, _ := l.Lock(ctx, "key", "a", time.Second); !ok {
+		t.Fatal("Lock failed on a free key")
+	}
+	if ok, _ := l.Lock(ctx, "key", "a", time.Second); ok {
+		t.Fatal("Lock is reentrant, Redis SET NX is not")
+	}
+
+	// продление отодвигает TTL от текущего момента
+	clock.Advance(900 * time.Millisecond)
+	if ok, _ := l.ExtendLockTTL(ctx, "key", "a", time.Second); !ok {
+		t.Fatal("owner failed to extend")
+	}
+	clock.Advance(900 * time.Millisecond)
+	if ok, _ := l.Lock(ctx, "key", "b", time.Second); ok {
+		t.Fatal("extended lock expired at the old TTL")
+	}
+	if ok, _ := l.ExtendLockTTL(ctx, "key", "b", time.Second); ok {
+		t.Fatal("non-owner extended the lock")
+	}
+
+	if ok, _ := l.Unlock(ctx, "key", "a"); !ok {
+		t.Fatal("owner failed to unlock")
+	}
+	if ok, _ := l.Lock(ctx, "key", "b", time.Second); !ok {
+		t.Fatal("lock is not free after Unlock")
+	}
+}
+
+func TestMemoryLockerCancelledContext(t *testing.T) {
+	l := NewMemoryLocker()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.Lock(ctx, "key", "a", time.Second); err == nil {
+		t.Fatal("Lock ignored a cancelled context")
+	}
+}
+
+func (locker *MemoryLocker) size() int {
+	locker.mu.Lock()
+	defer locker.mu.Unlock()
+	return len(locker.entries)
+}
+
+func TestMemoryLockerSweep(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewMemoryLocker(WithClock(clock.Now))
+	ctx := context.Background()
+
+	_, _ = l.Lock(ctx, "short", "a", time.Second)
+	_, _ = l.Lock(ctx, "long", "a", time.Minute)
+	clock.Advance(2 * time.Second)
+
+	// к истёкшему ключу не обращались — без Sweep он остаётся в памяти
+	if n := l.size(); n != 2 {
+		t.Fatalf("size %d before sweep, want 2", n)
+	}
+	l.Sweep()
+	if n := l.size(); n != 1 {
+		t.Fatalf("size %d after sweep, want 1", n)
+	}
+}
+
+func TestMemoryLockerBackgroundSweeper(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewMemoryLocker(WithClock(clock.Now))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, _ = l.Lock(ctx, "key", "a", time.Second)
+	l.StartSweeper(ctx, 5*time.Millisecond)
+	clock.Advance(time.Second)
+
+	deadline := time.Now().Add(time.Second)
+	for l.size() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("sweeper did not remove the expired key")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMemoryLockerLeaderLosesLockWithoutRenewal(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewMemoryLocker(WithClock(clock.Now))
+	ctx := context.Background()
+
+	if ok, _ := l.AcquireOrExtend(ctx, "leader", "a", time.Second); !ok {
+		t.Fatal("a did not become leader")
+	}
+	if ok, _ := l.AcquireOrExtend(ctx, "leader", "b", time.Second); ok {
+		t.Fatal("b took leadership while a holds it")
+	}
+	// a перестал продлевать
+	clock.Advance(time.Second)
+	if ok, _ := l.AcquireOrExtend(ctx, "leader", "b", time.Second); !ok {
+		t.Fatal("b did not take leadership after a's TTL")
+	}
+	if ok, _ := l.AcquireOrExtend(ctx, "leader", "a", time.Second); ok {
+		t.Fatal("a renewed a lock it already lost")
+	}
+}
@@ -19,10 +19,34 @@ const (
 
 	// Скрипт для продления блокировки
 	extendTTLScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+
+	// Скрипт для взятия свободной или продления своей блокировки
+	acquireOrExtendScript = `local v = redis.call("GET", KEYS[1])
+if v == false then redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2]) return 1 end
+if v == ARGV[1] then redis.call("PEXPIRE", KEYS[1], ARGV[2]) return 1 end
+return 0`
+
+	// Скрипт для продления пачки блокировок: ARGV[1] — TTL, ARGV[i+1] — владелец KEYS[i]
+	extendManyScript = `local res = {}
+for i, key in ipairs(KEYS) do
+if redis.call("GET", key) == ARGV[i + 1] then res[i] = redis.call("PEXPIRE", key, ARGV[1]) else res[i] = 0 end
+end
+return res`
 )
 
 type RedisLocker struct {
 	redisClient *redis.Client
+	opTimeout   time.Duration
+}
+
+type RedisLockerOption func(*RedisLocker)
+
+// WithOperationTimeout ограничивает каждую операцию с Redis, чтобы зависшее соединение
+// не блокировало продление лидерства в watchdog. 0 — без ограничения (только ctx вызывающего).
+func WithOperationTimeout(timeout time.Duration) RedisLockerOption {
+	return func(locker *RedisLocker) {
+		locker.opTimeout = timeout
+	}
 }
 
 type LockerConfig struct {
@@ -32,14 +56,18 @@ type LockerConfig struct {
 	DB       int
 }
 
-func NewLocker(c *redis.Client) Locker {
-	return &RedisLocker{
+func NewLocker(c *redis.Client, opts ...RedisLockerOption) Locker {
+	locker := &RedisLocker{
 		redisClient: c,
 	}
+	for _, opt := range opts {
+		opt(locker)
+	}
+	return locker
 }
 
 func (locker *RedisLocker) Lock(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
-	result, err := locker.redisClient.Eval(ctx, lockScript, []string{lockKeyPrefix + key}, value, expiration.Milliseconds()).Int()
+	result, err := locker.eval(ctx, lockScript, []string{lockKeyPrefix + key}, value, expiration.Milliseconds())
 	if err != nil {
 		return false, fmt.Errorf("eval: %v", err)
 	}
@@ -48,7 +76,7 @@ func (locker *RedisLocker) Lock(ctx context.Context, key, value string, expirati
 }
 
 func (locker *RedisLocker) Unlock(ctx context.Context, key, value string) (bool, error) {
-	result, err := locker.redisClient.Eval(ctx, unlockScript, []string{lockKeyPrefix + key}, value).Int()
+	result, err := locker.eval(ctx, unlockScript, []string{lockKeyPrefix + key}, value)
 	if err != nil {
 		return false, fmt.Errorf("eval: %v", err)
 	}
@@ -57,10 +85,66 @@ func (locker *RedisLocker) Unlock(ctx context.Context, key, value string) (bool,
 }
 
 func (locker *RedisLocker) ExtendLockTTL(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
-	result, err := locker.redisClient.Eval(ctx, extendTTLScript, []string{lockKeyPrefix + key}, value, expiration.Milliseconds()).Int()
+	result, err := locker.eval(ctx, extendTTLScript, []string{lockKeyPrefix + key}, value, expiration.Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("eval: %v", err)
+	}
+
+	return result == 1, nil
+}
+
+func (locker *RedisLocker) LockWithRetry(ctx context.Context, p Parameters) (bool, error) {
+	return lockWithRetry(ctx, locker.Lock, p)
+}
+
+func (locker *RedisLocker) AcquireOrExtend(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	result, err := locker.eval(ctx, acquireOrExtendScript, []string{lockKeyPrefix + key}, value, expiration.Milliseconds())
 	if err != nil {
 		return false, fmt.Errorf("eval: %v", err)
 	}
 
 	return result == 1, nil
 }
+
+// ExtendMany продлевает все leases одним вызовом скрипта. ok[i] == false — leases[i] уже не наша,
+// остальные от этого не зависят. В Redis Cluster ключи пачки должны быть в одном слоте.
+func (locker *RedisLocker) ExtendMany(ctx context.Context, leases []Lease, expiration time.Duration) ([]bool, error) {
+	if len(leases) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(leases))
+	args := make([]interface{}, 0, len(leases)+1)
+	args = append(args, expiration.Milliseconds())
+	for i, l := range leases {
+		keys[i] = lockKeyPrefix + l.Key
+		args = append(args, l.Value)
+	}
+
+	if locker.opTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, locker.opTimeout)
+		defer cancel()
+	}
+	res, err := locker.redisClient.Eval(ctx, extendManyScript, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("eval: %v", err)
+	}
+	if len(res) != len(leases) {
+		return nil, fmt.Errorf("eval: got %d results for %d keys", len(res), len(leases))
+	}
+
+	ok := make([]bool, len(res))
+	for i, r := range res {
+		ok[i] = r == 1
+	}
+	return ok, nil
+}
+
+func (locker *RedisLocker) eval(ctx context.Context, script string, keys []string, args ...interface{}) (int, error) {
+	if locker.opTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, locker.opTimeout)
+		defer cancel()
+	}
+	return locker.redisClient.Eval(ctx, script, keys, args...).Int()
+}
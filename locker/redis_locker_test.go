@@ -0,0 +1,229 @@
+package locker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// hangingRedis принимает соединения и ничего не отвечает, как зависший Redis
+func hangingRedis(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		_ = lis.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	})
+	return lis.Addr().String()
+}
+
+func TestRedisLockerOperationTimeout(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: hangingRedis(t), ReadTimeout: time.Minute, MaxRetries: -1})
+	defer client.Close()
+	l := NewLocker(client, WithOperationTimeout(100*time.Millisecond))
+
+	ops := map[string]func(ctx context.Context) error{
+		"Lock": func(ctx context.Context) error {
+			_, err := l.Lock(ctx, "key", "v", time.Second)
+			return err
+		},
+		"Unlock": func(ctx context.Context) error {
+			_, err := l.Unlock(ctx, "key", "v")
+			return err
+		},
+		"ExtendLockTTL": func(ctx context.Context) error {
+			_, err := l.ExtendLockTTL(ctx, "key", "v", time.Second)
+			return err
+		},
+		"AcquireOrExtend": func(ctx context.Context) error {
+			_, err := l.AcquireOrExtend(ctx, "key", "v", time.Second)
+			return err
+		},
+		"ExtendMany": func(ctx context.Context) error {
+			_, err := l.(*RedisLocker).ExtendMany(ctx, []Lease{{Key: "key", Value: "v"}}, time.Second)
+			return err
+		},
+	}
+	for name, op := range ops {
+		start := time.Now()
+		err := op(context.Background())
+		if err == nil {
+			t.Fatalf("%s succeeded against a hanging redis", name)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("%s returned after %s, operation timeout is 100ms", name, elapsed)
+		}
+	}
+}
+
+// scriptRedis RESP-сервер, который исполняет acquireOrExtendScript и extendManyScript над map в памяти.
+// Проверяет, что RedisLocker передаёт ключи, владельцев и TTL так, как их ждут скрипты.
+type scriptRedis struct {
+	mu    sync.Mutex
+	keys  map[string]string
+	ttls  map[string]string
+	evals int
+}
+
+func startScriptRedis(t *testing.T) (*scriptRedis, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+	s := &scriptRedis{keys: map[string]string{}, ttls: map[string]string{}}
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s, lis.Addr().String()
+}
+
+func (s *scriptRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) < 3 || !strings.EqualFold(args[0], "eval") {
+			_, _ = io.WriteString(c, "-ERR unsupported command\r\n")
+			continue
+		}
+		s.mu.Lock()
+		s.evals++
+		switch {
+		case args[1] == acquireOrExtendScript && len(args) == 6:
+			// EVAL script 1 key value ttl
+			key, value, ttl := args[3], args[4], args[5]
+			held := 0
+			if cur, ok := s.keys[key]; !ok || cur == value {
+				s.keys[key], s.ttls[key] = value, ttl
+				held = 1
+			}
+			_, _ = fmt.Fprintf(c, ":%d\r\n", held)
+		case args[1] == extendManyScript:
+			// EVAL script n key1..keyN ttl value1..valueN
+			n, _ := strconv.Atoi(args[2])
+			keys, ttl, values := args[3:3+n], args[3+n], args[4+n:]
+			_, _ = fmt.Fprintf(c, "*%d\r\n", n)
+			for i, key := range keys {
+				extended := 0
+				if cur, ok := s.keys[key]; ok && cur == values[i] {
+					s.ttls[key] = ttl
+					extended = 1
+				}
+				_, _ = fmt.Fprintf(c, ":%d\r\n", extended)
+			}
+		default:
+			_, _ = io.WriteString(c, "-ERR unsupported script\r\n")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2) // с \r\n
+		if _, err = io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func testAcquireOrExtend(t *testing.T, l Locker) {
+	ctx := context.Background()
+	if ok, err := l.AcquireOrExtend(ctx, "key", "a", time.Second); !ok || err != nil {
+		t.Fatalf("absent key: ok %v, err %v; want acquired", ok, err)
+	}
+	if ok, err := l.AcquireOrExtend(ctx, "key", "a", 2*time.Second); !ok || err != nil {
+		t.Fatalf("owned key: ok %v, err %v; want extended", ok, err)
+	}
+	if ok, err := l.AcquireOrExtend(ctx, "key", "b", time.Second); ok || err != nil {
+		t.Fatalf("key owned by other: ok %v, err %v; want not held", ok, err)
+	}
+}
+
+func TestAcquireOrExtendRedis(t *testing.T) {
+	srv, addr := startScriptRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	testAcquireOrExtend(t, NewLocker(client))
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	key := lockKeyPrefix + "key"
+	if srv.keys[key] != "a" || srv.ttls[key] != "2000" {
+		t.Fatalf("stored %q with ttl %q, want owner a with the extended ttl 2000", srv.keys[key], srv.ttls[key])
+	}
+}
+
+func TestAcquireOrExtendMemory(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	l := NewMemoryLocker(WithClock(clock.Now))
+	testAcquireOrExtend(t, l)
+
+	// продление от владельца отодвинуло TTL до 2s
+	clock.Advance(1500 * time.Millisecond)
+	if ok, _ := l.AcquireOrExtend(context.Background(), "key", "b", time.Second); ok {
+		t.Fatal("extended lock expired at the original TTL")
+	}
+}
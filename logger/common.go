@@ -1,6 +1,9 @@
 package logger_wrapper
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type LogEntry struct {
 	Msg       string
@@ -10,4 +13,17 @@ type LogEntry struct {
 	Component string
 	Method    string
 	Start     *time.Time
+	// Fields дополнительные поля записи. В cloud (JSON) режиме пишутся отдельными ключами,
+	// в консольном — дописываются в сообщение в порядке сортировки ключей.
+	Fields map[string]any
+	// KeepEmptyResult пишет result даже если он nil или пустая строка (для аудита: «пустой результат» != «не логировали»)
+	KeepEmptyResult bool
+}
+
+// Logger интерфейс для внедрения логгера в компоненты вместо пакетных функций zap_engine:
+// в тестах можно подставить свою реализацию и проверить записи. Реализация по умолчанию — zap_engine.Global().
+type Logger interface {
+	Info(ctx context.Context, entry *LogEntry)
+	Warn(ctx context.Context, entry *LogEntry)
+	Error(ctx context.Context, entry *LogEntry)
 }
@@ -0,0 +1,73 @@
+package zap_engine
+
+import (
+	"context"
+	"sort"
+)
+
+type ctxFieldsKey struct{}
+
+type ctxDebugTraceKey struct{}
+
+// WithDebugTrace помечает контекст: записи с ним пишутся начиная с debug, независимо от текущего уровня логгера.
+// Нужен, чтобы включить подробные логи для одного запроса, не заливая ими всё остальное.
+func WithDebugTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxDebugTraceKey{}, true)
+}
+
+func IsDebugTrace(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	on, _ := ctx.Value(ctxDebugTraceKey{}).(bool)
+	return on
+}
+
+// WithContextField кладёт поле в контекст: оно попадёт в каждую запись Write*Log, сделанную с этим контекстом
+// или с любым производным от него (в том числе внутри utils.GoRecover).
+func WithContextField(ctx context.Context, key string, val any) context.Context {
+	return WithContextFields(ctx, map[string]any{key: val})
+}
+
+func WithContextFields(ctx context.Context, fields map[string]any) context.Context {
+	prev := ContextFields(ctx)
+	merged := make(map[string]any, len(prev)+len(fields))
+	for k, v := range prev {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, ctxFieldsKey{}, merged)
+}
+
+// ContextFields возвращает поля, положенные в контекст. Мапу менять нельзя.
+func ContextFields(ctx context.Context) map[string]any {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(ctxFieldsKey{}).(map[string]any)
+	return fields
+}
+
+// contextFields поля из контекста в порядке ключей; ключи, заданные в самой записи, имеют приоритет.
+func contextFields(ctx context.Context, own map[string]any) []Field {
+	fields := ContextFields(ctx)
+	if len(fields) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if _, ok := own[k]; ok {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]Field, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, WithField(k, fields[k]))
+	}
+	return out
+}
@@ -0,0 +1,26 @@
+package zap_engine
+
+import (
+	"context"
+
+	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
+)
+
+type globalLogger struct{}
+
+// Global реализация logger.Logger поверх пакетных Write*Log, то есть глобального логгера.
+func Global() loggerwrapper.Logger {
+	return globalLogger{}
+}
+
+func (globalLogger) Info(ctx context.Context, entry *loggerwrapper.LogEntry) {
+	WriteInfoLog(ctx, entry)
+}
+
+func (globalLogger) Warn(ctx context.Context, entry *loggerwrapper.LogEntry) {
+	WriteWarnLog(ctx, entry)
+}
+
+func (globalLogger) Error(ctx context.Context, entry *loggerwrapper.LogEntry) {
+	WriteErrorLog(ctx, entry)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
+	"go.uber.org/zap/zapcore"
 )
 
 func FlushLogs() {
@@ -11,31 +12,46 @@ func FlushLogs() {
 }
 
 func WriteInfoLog(ctx context.Context, entry *loggerwrapper.LogEntry) {
-	msg, fields := unpack(entry)
-	Info(ctx, buildMessage(msg, "info", fields))
+	write(ctx, zapcore.InfoLevel, entry)
 }
 
 func WriteDebugLog(ctx context.Context, entry *loggerwrapper.LogEntry) {
-	msg, fields := unpack(entry)
-	Debug(ctx, buildMessage(msg, "debug", fields))
+	write(ctx, zapcore.DebugLevel, entry)
 }
 
 func WriteWarnLog(ctx context.Context, entry *loggerwrapper.LogEntry) {
-	msg, fields := unpack(entry)
-	Warn(ctx, buildMessage(msg, "warn", fields))
+	write(ctx, zapcore.WarnLevel, entry)
 }
 
 func WriteErrorLog(ctx context.Context, entry *loggerwrapper.LogEntry) {
-	msg, fields := unpack(entry)
-	Error(ctx, buildMessage(msg, "error", fields))
+	write(ctx, zapcore.ErrorLevel, entry)
 }
 
 func WritePanicLog(ctx context.Context, entry *loggerwrapper.LogEntry) {
-	msg, fields := unpack(entry)
-	Panic(ctx, buildMessage(msg, "panic", fields))
+	write(ctx, zapcore.PanicLevel, entry)
 }
 
 func WriteFatalLog(ctx context.Context, entry *loggerwrapper.LogEntry) {
+	write(ctx, zapcore.FatalLevel, entry)
+}
+
+// write в cloud режиме отдаёт поля в zap как есть, чтобы JSON-энкодер записал их отдельными ключами,
+// в консольном режиме склеивает их в одно сообщение.
+// Уровень проверяется до unpack/buildMessage, чтобы отброшенные записи (debug в горячих путях) ничего не стоили.
+func write(ctx context.Context, level zapcore.Level, entry *loggerwrapper.LogEntry) {
+	l := log
+	// как и в zap, panic/fatal не отбрасываем: они должны прервать выполнение при любом уровне
+	if level < zapcore.DPanicLevel && !log.Core().Enabled(level) {
+		if !IsDebugTrace(ctx) {
+			return
+		}
+		l = traceLog
+	}
 	msg, fields := unpack(entry)
-	Fatal(ctx, buildMessage(msg, "fatal", fields))
+	fields = append(fields, contextFields(ctx, entry.Fields)...)
+	if structured {
+		l.Log(level, msg, zapFields(fields)...)
+		return
+	}
+	l.Log(level, buildMessage(msg, level.String(), fields))
 }
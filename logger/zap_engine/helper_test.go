@@ -0,0 +1,79 @@
+package zap_engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDisabledLevelIsDropped(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger, logtest.WithLevel(zapcore.InfoLevel))
+
+	WriteDebugLog(context.Background(), &loggerwrapper.LogEntry{Msg: "debug"})
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "info"})
+
+	if entries := logs.Entries(); len(entries) != 1 || entries[0]["message"] != "info" {
+		t.Fatalf("entries %v, want only info", entries)
+	}
+}
+
+func TestEnabledDebugWritesFullEntry(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger)
+	start := time.Now()
+
+	WriteDebugLog(context.Background(), &loggerwrapper.LogEntry{
+		Msg:       "debug",
+		Component: "test",
+		Method:    "Run",
+		Args:      "arg",
+		Error:     errors.New("failed"),
+		Start:     &start,
+		Fields:    map[string]any{"k": "v"},
+	})
+
+	entries := logs.WithMessage("debug")
+	if len(entries) != 1 {
+		t.Fatalf("%d debug entries, want 1", len(entries))
+	}
+	e := entries[0]
+	for key, want := range map[string]any{"level": "debug", "component": "test", "method": "Run", "args": "arg", "error": "failed", "k": "v"} {
+		if e[key] != want {
+			t.Fatalf("%s = %v, want %v (entry %v)", key, e[key], want, e)
+		}
+	}
+	if _, ok := e["latency"]; !ok {
+		t.Fatalf("latency missing: %v", e)
+	}
+}
+
+func benchmarkDebug(b *testing.B, level zapcore.Level) {
+	if err := InitLogger(level, false, nil, zapcore.AddSync(io.Discard)); err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = InitLogger(zapcore.DebugLevel, true, nil, zapcore.AddSync(io.Discard)) })
+	ctx := context.Background()
+	start := time.Now()
+	entry := &loggerwrapper.LogEntry{
+		Msg:       "hot path",
+		Component: "bench",
+		Method:    "Run",
+		Args:      map[string]int{"a": 1, "b": 2},
+		Start:     &start,
+		Fields:    map[string]any{"k": "v"},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		WriteDebugLog(ctx, entry)
+	}
+}
+
+func BenchmarkWriteDebugLogDisabled(b *testing.B) { benchmarkDebug(b, zapcore.InfoLevel) }
+
+func BenchmarkWriteDebugLogEnabled(b *testing.B) { benchmarkDebug(b, zapcore.DebugLevel) }
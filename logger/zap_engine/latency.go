@@ -0,0 +1,117 @@
+package zap_engine
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyRecorder получает латентность каждой записи с заданным LogEntry.Start.
+// Подходит обёртка над prometheus HistogramVec или встроенный LatencyHistogram.
+type LatencyRecorder interface {
+	ObserveLatency(component, method string, latency time.Duration)
+}
+
+var latencyRecorder atomic.Pointer[LatencyRecorder]
+
+// SetLatencyRecorder включает запись латентностей логгера в recorder, nil — выключает.
+// Наблюдаются только записи, прошедшие фильтр уровня.
+func SetLatencyRecorder(recorder LatencyRecorder) {
+	if recorder == nil {
+		latencyRecorder.Store(nil)
+		return
+	}
+	latencyRecorder.Store(&recorder)
+}
+
+func observeLatency(component, method string, latency time.Duration) {
+	if r := latencyRecorder.Load(); r != nil {
+		(*r).ObserveLatency(component, method, latency)
+	}
+}
+
+// DefaultLatencyBuckets верхние границы корзин LatencyHistogram по умолчанию
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type latencyKey struct {
+	component string
+	method    string
+}
+
+// LatencyHistogram простая гистограмма латентностей по паре component/method для SLO (p99 и т.п.)
+// без отдельного пути метрик.
+type LatencyHistogram struct {
+	mu     sync.Mutex
+	bounds []time.Duration
+	series map[latencyKey][]uint64 // последняя корзина — всё, что больше верхней границы
+}
+
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &LatencyHistogram{
+		bounds: bounds,
+		series: make(map[latencyKey][]uint64),
+	}
+}
+
+func (h *LatencyHistogram) ObserveLatency(component, method string, latency time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return latency <= h.bounds[i] })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := latencyKey{component: component, method: method}
+	counts, ok := h.series[key]
+	if !ok {
+		counts = make([]uint64, len(h.bounds)+1)
+		h.series[key] = counts
+	}
+	counts[i]++
+}
+
+// Counts копия счётчиков по корзинам: i-я — латентности <= bounds[i], последняя — выше всех границ.
+func (h *LatencyHistogram) Counts(component, method string) []uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]uint64(nil), h.series[latencyKey{component: component, method: method}]...)
+}
+
+// Quantile оценка квантиля q (0..1) — верхняя граница корзины, в которую он попал.
+// Для значений выше последней границы возвращает math.MaxInt64, без наблюдений — 0.
+func (h *LatencyHistogram) Quantile(component, method string, q float64) time.Duration {
+	counts := h.Counts(component, method)
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := max(uint64(math.Ceil(q*float64(total))), 1)
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen >= rank && i < len(h.bounds) {
+			return h.bounds[i]
+		}
+	}
+	return time.Duration(math.MaxInt64)
+}
@@ -0,0 +1,88 @@
+package zap_engine
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+// latencySpy запоминает наблюдения
+type latencySpy struct {
+	mu  sync.Mutex
+	got []time.Duration
+}
+
+func (s *latencySpy) ObserveLatency(component, method string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if component == "db" && method == "Query" {
+		s.got = append(s.got, latency)
+	}
+}
+
+func TestLoggerFeedsLatencyRecorder(t *testing.T) {
+	logtest.Capture(t, InitLogger, logtest.WithLevel(zapcore.InfoLevel))
+	spy := &latencySpy{}
+	SetLatencyRecorder(spy)
+	defer SetLatencyRecorder(nil)
+
+	start := time.Now().Add(-30 * time.Millisecond)
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "query", Component: "db", Method: "Query", Start: &start})
+	// без Start и ниже уровня — не наблюдаются
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "query", Component: "db", Method: "Query"})
+	WriteDebugLog(context.Background(), &loggerwrapper.LogEntry{Msg: "query", Component: "db", Method: "Query", Start: &start})
+
+	if len(spy.got) != 1 {
+		t.Fatalf("%d observations, want 1", len(spy.got))
+	}
+	if spy.got[0] < 30*time.Millisecond || spy.got[0] > time.Second {
+		t.Fatalf("observed %s, want about 30ms", spy.got[0])
+	}
+}
+
+func TestLatencyHistogramBuckets(t *testing.T) {
+	h := NewLatencyHistogram(100*time.Millisecond, 10*time.Millisecond)
+	for _, d := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		h.ObserveLatency("db", "Query", d)
+	}
+
+	counts := h.Counts("db", "Query")
+	want := []uint64{2, 1, 1}
+	for i := range want {
+		if counts[i] != want[i] {
+			t.Fatalf("counts %v, want %v", counts, want)
+		}
+	}
+	if q := h.Quantile("db", "Query", 0.5); q != 10*time.Millisecond {
+		t.Fatalf("p50 %s, want 10ms", q)
+	}
+	if q := h.Quantile("db", "Query", 0.75); q != 100*time.Millisecond {
+		t.Fatalf("p75 %s, want 100ms", q)
+	}
+	if q := h.Quantile("db", "Query", 0.99); q != time.Duration(math.MaxInt64) {
+		t.Fatalf("p99 %s, want overflow", q)
+	}
+	if q := h.Quantile("db", "Other", 0.99); q != 0 {
+		t.Fatalf("quantile without observations %s, want 0", q)
+	}
+}
+
+func TestLatencyHistogramThroughLogger(t *testing.T) {
+	logtest.Capture(t, InitLogger)
+	h := NewLatencyHistogram()
+	SetLatencyRecorder(h)
+	defer SetLatencyRecorder(nil)
+
+	start := time.Now().Add(-30 * time.Millisecond)
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "query", Component: "db", Method: "Query", Start: &start})
+
+	if q := h.Quantile("db", "Query", 1); q != 50*time.Millisecond {
+		t.Fatalf("p100 %s, want the 50ms bucket", q)
+	}
+}
@@ -0,0 +1,59 @@
+package zap_engine
+
+import (
+	"context"
+	"math"
+	"time"
+
+	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+type Lifecycle string
+
+const (
+	LifecycleStarting Lifecycle = "starting"
+	LifecycleStarted  Lifecycle = "started"
+	LifecycleStopping Lifecycle = "stopping"
+	LifecycleStopped  Lifecycle = "stopped"
+)
+
+// LogLifecycle единый формат событий запуска/остановки подсистем для дашбордов:
+// сообщение "<component> <state>" и поля component, state (starting|started|stopping|stopped) плюс fields.
+func LogLifecycle(ctx context.Context, component string, state Lifecycle, fields ...Field) {
+	entry := &loggerwrapper.LogEntry{
+		Msg:       component + " " + string(state),
+		Component: component,
+		Method:    "lifecycle",
+		Fields:    map[string]any{"state": string(state)},
+	}
+	for _, f := range fields {
+		if f.Key != "" && !isEmpty(f) {
+			entry.Fields[f.Key] = f.value()
+		}
+	}
+	WriteInfoLog(ctx, entry)
+}
+
+// value обратное к WithField: исходное значение поля
+func (f Field) value() any {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.BoolType:
+		return f.Integer == 1
+	case zapcore.Int64Type:
+		return f.Integer
+	case zapcore.Uint64Type:
+		if f.Interface != nil {
+			return f.Interface
+		}
+		return uint64(f.Integer)
+	case zapcore.Float64Type:
+		return math.Float64frombits(uint64(f.Integer))
+	case zapcore.DurationType:
+		return time.Duration(f.Integer)
+	default:
+		return f.Interface
+	}
+}
@@ -0,0 +1,50 @@
+package zap_engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+)
+
+func TestLogLifecycleNormalizedFields(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger)
+
+	LogLifecycle(context.Background(), "HTTPServer", LifecycleStarted,
+		WithField("addr", ":8080"), WithField("workers", 4), WithField("tls", false), WithField("", "skipped"))
+
+	entries := logs.WithMessage("HTTPServer started")
+	if len(entries) != 1 {
+		t.Fatalf("%d lifecycle entries, want 1", len(entries))
+	}
+	e := entries[0]
+	want := map[string]any{
+		"level":     "info",
+		"component": "HTTPServer",
+		"method":    "lifecycle",
+		"state":     "started",
+		"addr":      ":8080",
+		"workers":   float64(4),
+		"tls":       false,
+	}
+	for k, v := range want {
+		if e[k] != v {
+			t.Errorf("%s = %v, want %v", k, e[k], v)
+		}
+	}
+	if _, ok := e[""]; ok {
+		t.Error("field without a key was logged")
+	}
+}
+
+func TestLogLifecycleStates(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger)
+
+	for _, state := range []Lifecycle{LifecycleStarting, LifecycleStarted, LifecycleStopping, LifecycleStopped} {
+		LogLifecycle(context.Background(), "scheduler", state)
+		entries := logs.WithMessage("scheduler " + string(state))
+		if len(entries) != 1 || entries[0]["state"] != string(state) || entries[0]["component"] != "scheduler" {
+			t.Fatalf("state %s: entries %v", state, entries)
+		}
+	}
+}
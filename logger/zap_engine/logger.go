@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
 
@@ -20,9 +23,44 @@ import (
 var (
 	log         = zap.NewNop()
 	atomicLevel zap.AtomicLevel // для динамического изменения уровня
+	structured  bool            // cloud режим: поля пишутся отдельными ключами, а не в сообщение
+	traceLog    = zap.NewNop()  // тот же вывод, но с уровнем debug — для записей с WithDebugTrace
 )
 
+type BufferConfig struct {
+	// Size размер буфера в байтах, 0 — 256 kB (дефолт zap)
+	Size int
+	// FlushInterval период сброса буфера, 0 — 30s (дефолт zap)
+	FlushInterval time.Duration
+}
+
 func InitLoggerForStdout(level zapcore.Level, cloud bool, cfg *zapcore.EncoderConfig, option ...zap.Option) error {
+	return initLogger(level, cloud, cfg, zapcore.Lock(os.Stdout), option...)
+}
+
+// InitBufferedLoggerForStdout как InitLoggerForStdout, но запись в stdout идёт через буфер,
+// который сбрасывается по заполнению, раз в FlushInterval и на FlushLogs (App.FlushLogger).
+// Снимает задержку логирования с горячих путей ценой надёжности: при падении процесса
+// (panic без recover, kill -9, OOM) записи из буфера за последний FlushInterval теряются.
+func InitBufferedLoggerForStdout(level zapcore.Level, cloud bool, cfg *zapcore.EncoderConfig, buffer BufferConfig, option ...zap.Option) error {
+	return initBufferedLogger(level, cloud, cfg, zapcore.AddSync(os.Stdout), buffer, option...)
+}
+
+func initBufferedLogger(level zapcore.Level, cloud bool, cfg *zapcore.EncoderConfig, ws zapcore.WriteSyncer, buffer BufferConfig, option ...zap.Option) error {
+	return initLogger(level, cloud, cfg, &zapcore.BufferedWriteSyncer{
+		WS:            ws,
+		Size:          buffer.Size,
+		FlushInterval: buffer.FlushInterval,
+	}, option...)
+}
+
+// InitLogger инициализирует логгер с произвольным выводом, например
+// NewResilientWriteSyncer(zapcore.Lock(os.Stdout), 5, 10*time.Second).
+func InitLogger(level zapcore.Level, cloud bool, cfg *zapcore.EncoderConfig, ws zapcore.WriteSyncer, option ...zap.Option) error {
+	return initLogger(level, cloud, cfg, ws, option...)
+}
+
+func initLogger(level zapcore.Level, cloud bool, cfg *zapcore.EncoderConfig, ws zapcore.WriteSyncer, option ...zap.Option) error {
 	atomicLevel = zap.NewAtomicLevelAt(level)
 
 	var encCfg zapcore.EncoderConfig
@@ -43,6 +81,8 @@ func InitLoggerForStdout(level zapcore.Level, cloud bool, cfg *zapcore.EncoderCo
 		encCfg = *cfg
 	}
 
+	structured = cloud
+
 	var enc zapcore.Encoder
 	if cloud {
 		enc = zapcore.NewJSONEncoder(encCfg)
@@ -50,8 +90,6 @@ func InitLoggerForStdout(level zapcore.Level, cloud bool, cfg *zapcore.EncoderCo
 		enc = zapcore.NewConsoleEncoder(encCfg)
 	}
 
-	ws := zapcore.Lock(os.Stdout)
-
 	core := zapcore.NewCore(
 		enc,
 		zapcore.AddSync(ws),
@@ -65,10 +103,34 @@ func InitLoggerForStdout(level zapcore.Level, cloud bool, cfg *zapcore.EncoderCo
 	opt = append(opt, option...)
 
 	log = zap.New(core, opt...)
+	traceLog = zap.New(zapcore.NewCore(enc, zapcore.AddSync(ws), zapcore.DebugLevel), opt...)
 
 	return nil
 }
 
+// WithInstanceFields опция для InitLoggerForStdout: добавляет в каждую запись hostname, pid
+// и пользовательские метки инстанса (pod, zone и т.п.).
+func WithInstanceFields(labels map[string]string) zap.Option {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	fields := []zap.Field{
+		zap.String("hostname", hostname),
+		zap.Int("pid", os.Getpid()),
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fields = append(fields, zap.String(k, labels[k]))
+	}
+	return zap.Fields(fields...)
+}
+
 // SetLevel Позволяет менять уровень в рантайме
 func SetLevel(level string) error {
 	return atomicLevel.UnmarshalText([]byte(level))
@@ -97,12 +159,24 @@ func Fatal(ctx context.Context, msg string) {
 	log.Fatal(msg)
 }
 
+// emptyFieldValue так пустое поле с Always выглядит в консольном режиме
+const emptyFieldValue = "<empty>"
+
 type Field struct {
 	Key       string
 	Type      zapcore.FieldType
 	String    string
 	Integer   int64
 	Interface any
+	// Always пустое значение (nil, "") не отбрасывается, а пишется явно
+	Always bool
+}
+
+// WithFieldAlways как WithField, но пустое значение попадёт в лог: "<empty>" в консоли, null/"" в JSON.
+func WithFieldAlways(key string, val any) Field {
+	f := WithField(key, val)
+	f.Always = true
+	return f
 }
 
 func WithField(key string, val any) Field {
@@ -226,7 +300,7 @@ func buildMessage(msg string, level string, fields []Field) string {
 			sb.WriteString(k)
 			sb.WriteByte('=')
 		}
-		sb.WriteString(v)
+		writeEscaped(&sb, v)
 	}
 
 	sb.WriteString(", level->")
@@ -238,7 +312,48 @@ func buildMessage(msg string, level string, fields []Field) string {
 	return sb.String()
 }
 
+// writeEscaped пишет строку, экранируя непечатаемые символы и битый UTF-8, чтобы бинарные данные
+// не ломали терминал в консольном режиме. В JSON режиме buildMessage не используется, там экранирует энкодер.
+func writeEscaped(sb *strings.Builder, s string) {
+	clean := true
+	for _, r := range s {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		sb.WriteString(s)
+		return
+	}
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(sb, `\x%02x`, s[i])
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r < 0x80:
+			fmt.Fprintf(sb, `\x%02x`, r)
+		default:
+			fmt.Fprintf(sb, `\u%04x`, r)
+		}
+		i += size
+	}
+}
+
 func kv(f Field) (string, string, bool) {
+	if f.Always && f.Key != "" && isEmpty(f) {
+		return f.Key, emptyFieldValue, true
+	}
+
 	switch f.Type {
 	case zapcore.StringType:
 		if f.String == "" {
@@ -286,23 +401,98 @@ func kv(f Field) (string, string, bool) {
 	}
 }
 
+func resultField(entry *loggerwrapper.LogEntry) Field {
+	if entry.KeepEmptyResult {
+		return WithFieldAlways("result", entry.Result)
+	}
+	return WithField("result", entry.Result)
+}
+
 func unpack(entry *loggerwrapper.LogEntry) (string, []Field) {
+	var fields []Field
 	if entry.Start == nil {
-		return entry.Msg, []Field{
+		fields = []Field{
 			WithField("component", entry.Component),
 			WithField("method", entry.Method),
 			WithField("args", entry.Args),
-			WithField("result", entry.Result),
+			resultField(entry),
 			WithField("latency", ""),
 			WithError(entry.Error),
 		}
+	} else {
+		latency := time.Since(*entry.Start)
+		observeLatency(entry.Component, entry.Method, latency)
+		fields = []Field{
+			WithField("component", entry.Component),
+			WithField("method", entry.Method),
+			WithField("args", entry.Args),
+			resultField(entry),
+			WithField("latency", fmt.Sprintf("%v ms", latency.Milliseconds())),
+			WithError(entry.Error),
+		}
+	}
+
+	if len(entry.Fields) > 0 {
+		keys := make([]string, 0, len(entry.Fields))
+		for k := range entry.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fields = append(fields, WithField(k, entry.Fields[k]))
+		}
+	}
+	return entry.Msg, fields
+}
+
+// zapFields переводит Field в zap.Field для структурного режима, пустые поля пропускаются так же, как в kv.
+func zapFields(fields []Field) []zap.Field {
+	out := make([]zap.Field, 0, len(fields))
+	for _, f := range fields {
+		if isEmpty(f) {
+			if f.Always && f.Key != "" {
+				out = append(out, f.zapEmpty())
+			}
+			continue
+		}
+		out = append(out, f.zap())
+		// длительность дополнительно числом миллисекунд, чтобы по ней можно было фильтровать (timeout_ms > 100)
+		if f.Type == zapcore.DurationType {
+			out = append(out, zap.Int64(f.Key+"_ms", time.Duration(f.Integer).Milliseconds()))
+		}
 	}
-	return entry.Msg, []Field{
-		WithField("component", entry.Component),
-		WithField("method", entry.Method),
-		WithField("args", entry.Args),
-		WithField("result", entry.Result),
-		WithField("latency", fmt.Sprintf("%v ms", time.Since(*entry.Start).Milliseconds())),
-		WithError(entry.Error),
+	return out
+}
+
+func (f Field) zap() zap.Field {
+	switch {
+	// zap.Any сам подберёт поле: слайсы и мапы уйдут в JSON массивами/объектами, а не строкой
+	case f.Type == zapcore.ReflectType || (f.Type == zapcore.Uint64Type && f.Interface != nil):
+		return zap.Any(f.Key, f.Interface)
+	case f.Type == zapcore.BoolType:
+		return zap.Bool(f.Key, f.Integer == 1)
+	case f.Type == zapcore.DurationType:
+		return zap.Duration(f.Key, time.Duration(f.Integer))
+	}
+	return zap.Field{Key: f.Key, Type: f.Type, Integer: f.Integer, String: f.String, Interface: f.Interface}
+}
+
+func (f Field) zapEmpty() zap.Field {
+	if f.Type == zapcore.StringType {
+		return zap.String(f.Key, "")
+	}
+	return zap.Reflect(f.Key, nil)
+}
+
+func isEmpty(f Field) bool {
+	switch f.Type {
+	case zapcore.UnknownType:
+		return true
+	case zapcore.StringType:
+		return f.String == ""
+	case zapcore.ErrorType, zapcore.ReflectType, zapcore.StringerType:
+		return f.Interface == nil
+	default:
+		return false
 	}
 }
@@ -0,0 +1,221 @@
+package zap_engine
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	loggerwrapper "github.com/PavelAgarkov/service-pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestBuildMessageEscapesControlCharacters(t *testing.T) {
+	msg := buildMessage("msg", "info", []Field{WithField("data", "a\nb\x00c\xff\tend")})
+	want := `data=a\nb\x00c\xff\tend`
+	if !strings.Contains(msg, want) {
+		t.Fatalf("message %q does not contain %q", msg, want)
+	}
+}
+
+func TestBuildMessageKeepsPrintableUnicode(t *testing.T) {
+	msg := buildMessage("msg", "info", []Field{WithField("data", "привет, мир")})
+	if !strings.Contains(msg, "data=привет, мир") {
+		t.Fatalf("message %q mangled printable text", msg)
+	}
+}
+
+func TestConsoleOutputStaysOnOneLine(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger, logtest.Console())
+
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{
+		Msg:    "binary",
+		Fields: map[string]any{"data": "line1\nline2\x00\x1b[31m"},
+	})
+
+	lines := logs.Lines()
+	if len(lines) != 1 {
+		t.Fatalf("%d output lines, want 1: %q", len(lines), logs.String())
+	}
+	if !strings.Contains(lines[0], `data=line1\nline2\x00\x1b[31m`) {
+		t.Fatalf("console line %q lacks escaped data", lines[0])
+	}
+}
+
+func TestJSONOutputKeepsRawValue(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger)
+	raw := "line1\nline2\x00"
+
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "binary", Fields: map[string]any{"data": raw}})
+
+	entries := logs.WithMessage("binary")
+	if len(entries) != 1 || entries[0]["data"] != raw {
+		t.Fatalf("entries %v, want data %q", entries, raw)
+	}
+}
+
+func TestInstanceFieldsOnEveryEntry(t *testing.T) {
+	b := logtest.Capture(t, InitLogger, logtest.WithZapOptions(WithInstanceFields(map[string]string{"pod": "api-7f9c", "zone": "eu-1"})))
+
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "first"})
+	WriteWarnLog(context.Background(), &loggerwrapper.LogEntry{Msg: "second"})
+
+	hostname, _ := os.Hostname()
+	entries := b.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if e["pod"] != "api-7f9c" || e["zone"] != "eu-1" {
+			t.Fatalf("labels missing: %v", e)
+		}
+		if hostname != "" && e["hostname"] != hostname {
+			t.Fatalf("hostname %v, want %s", e["hostname"], hostname)
+		}
+		if pid, _ := e["pid"].(float64); int(pid) != os.Getpid() {
+			t.Fatalf("pid %v, want %d", e["pid"], os.Getpid())
+		}
+	}
+}
+
+func TestSlicesAndMapsAreJSONInCloudMode(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger)
+
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{
+		Msg: "structured",
+		Fields: map[string]any{
+			"tags":   []string{"a", "b"},
+			"counts": map[string]int{"x": 1, "y": 2},
+		},
+	})
+
+	entries := logs.WithMessage("structured")
+	if len(entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(entries))
+	}
+	tags, ok := entries[0]["tags"].([]any)
+	if !ok || len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Fatalf("tags %#v, want JSON array [a b]", entries[0]["tags"])
+	}
+	counts, ok := entries[0]["counts"].(map[string]any)
+	if !ok || counts["x"] != float64(1) || counts["y"] != float64(2) {
+		t.Fatalf("counts %#v, want JSON object {x:1 y:2}", entries[0]["counts"])
+	}
+}
+
+func TestResultField(t *testing.T) {
+	cases := []struct {
+		name    string
+		result  any
+		keep    bool
+		console string // "" — result в консоли не пишется
+		json    any
+		inJSON  bool
+	}{
+		{name: "nil dropped", result: nil},
+		{name: "empty dropped", result: ""},
+		{name: "nil kept", result: nil, keep: true, console: "result=<empty>", json: nil, inJSON: true},
+		{name: "empty kept", result: "", keep: true, console: "result=<empty>", json: "", inJSON: true},
+		{name: "populated", result: "ok", console: "result=ok", json: "ok", inJSON: true},
+		{name: "populated kept", result: "ok", keep: true, console: "result=ok", json: "ok", inJSON: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := &loggerwrapper.LogEntry{Msg: "audit", Component: "test", Result: tc.result, KeepEmptyResult: tc.keep}
+
+			console := logtest.Capture(t, InitLogger, logtest.Console())
+			WriteInfoLog(context.Background(), entry)
+			line := console.String()
+			if tc.console == "" && strings.Contains(line, "result=") {
+				t.Fatalf("console %q has result, want it omitted", line)
+			}
+			if tc.console != "" && !strings.Contains(line, tc.console) {
+				t.Fatalf("console %q lacks %q", line, tc.console)
+			}
+
+			cloud := logtest.Capture(t, InitLogger)
+			WriteInfoLog(context.Background(), entry)
+			entries := cloud.WithMessage("audit")
+			if len(entries) != 1 {
+				t.Fatalf("%d entries, want 1", len(entries))
+			}
+			got, ok := entries[0]["result"]
+			if ok != tc.inJSON || got != tc.json {
+				t.Fatalf("JSON result %#v (present %v), want %#v (present %v)", got, ok, tc.json, tc.inJSON)
+			}
+		})
+	}
+}
+
+func captureBuffered(t *testing.T, buffer BufferConfig) *logtest.Buffer {
+	t.Helper()
+	b := &logtest.Buffer{}
+	if err := initBufferedLogger(zapcore.DebugLevel, true, nil, b, buffer); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		FlushLogs()
+		_ = InitLogger(zapcore.DebugLevel, true, nil, zapcore.AddSync(io.Discard))
+	})
+	return b
+}
+
+func TestBufferedLoggerFlushesOnSync(t *testing.T) {
+	logs := captureBuffered(t, BufferConfig{Size: 1 << 20, FlushInterval: time.Hour})
+
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "buffered"})
+	if n := len(logs.WithMessage("buffered")); n != 0 {
+		t.Fatalf("%d entries written before flush, want 0", n)
+	}
+	FlushLogs()
+	if n := len(logs.WithMessage("buffered")); n != 1 {
+		t.Fatalf("%d entries after FlushLogs, want 1", n)
+	}
+}
+
+func TestBufferedLoggerFlushesOnInterval(t *testing.T) {
+	logs := captureBuffered(t, BufferConfig{Size: 1 << 20, FlushInterval: 20 * time.Millisecond})
+
+	WriteInfoLog(context.Background(), &loggerwrapper.LogEntry{Msg: "buffered"})
+	deadline := time.Now().Add(time.Second)
+	for len(logs.WithMessage("buffered")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("buffer was not flushed on the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBoolAndDurationFieldsAreNativeJSON(t *testing.T) {
+	logs := logtest.Capture(t, InitLogger)
+	ctx := WithContextField(context.Background(), "retry", false)
+
+	WriteInfoLog(ctx, &loggerwrapper.LogEntry{
+		Msg: "typed",
+		Fields: map[string]any{
+			"cached":  true,
+			"timeout": 1500 * time.Millisecond,
+		},
+	})
+
+	entries := logs.WithMessage("typed")
+	if len(entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if v, ok := e["cached"].(bool); !ok || !v {
+		t.Fatalf("cached %#v, want JSON true", e["cached"])
+	}
+	if v, ok := e["retry"].(bool); !ok || v {
+		t.Fatalf("retry %#v, want JSON false", e["retry"])
+	}
+	if e["timeout"] != "1.5s" {
+		t.Fatalf("timeout %#v, want \"1.5s\"", e["timeout"])
+	}
+	if v, ok := e["timeout_ms"].(float64); !ok || v != 1500 {
+		t.Fatalf("timeout_ms %#v, want JSON number 1500", e["timeout_ms"])
+	}
+}
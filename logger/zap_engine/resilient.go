@@ -0,0 +1,98 @@
+package zap_engine
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	DefaultMaxWriteFailures = 5
+	DefaultProbeInterval    = 10 * time.Second
+)
+
+// ResilientWriteSyncer защищает приложение от сломанного вывода логов (закрытый pipe и т.п.):
+// после maxFailures ошибок записи подряд записи молча отбрасываются, и только раз в probeInterval
+// делается пробная запись. Как только она проходит, вывод восстанавливается.
+// О переключениях пишется в stderr, без повторения на каждую запись.
+type ResilientWriteSyncer struct {
+	ws            zapcore.WriteSyncer
+	maxFailures   int
+	probeInterval time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	disabled  bool
+	nextProbe time.Time
+	dropped   int64
+}
+
+// NewResilientWriteSyncer maxFailures <= 0 — DefaultMaxWriteFailures, probeInterval <= 0 — DefaultProbeInterval.
+func NewResilientWriteSyncer(ws zapcore.WriteSyncer, maxFailures int, probeInterval time.Duration) *ResilientWriteSyncer {
+	if maxFailures <= 0 {
+		maxFailures = DefaultMaxWriteFailures
+	}
+	if probeInterval <= 0 {
+		probeInterval = DefaultProbeInterval
+	}
+	return &ResilientWriteSyncer{
+		ws:            ws,
+		maxFailures:   maxFailures,
+		probeInterval: probeInterval,
+	}
+}
+
+func (r *ResilientWriteSyncer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.disabled && time.Now().Before(r.nextProbe) {
+		r.dropped++
+		return len(p), nil
+	}
+
+	n, err := r.ws.Write(p)
+	if err == nil {
+		if r.disabled {
+			fmt.Fprintf(os.Stderr, "logger output recovered, %d records dropped\n", r.dropped)
+			r.disabled = false
+			r.dropped = 0
+		}
+		r.failures = 0
+		return n, nil
+	}
+
+	if r.disabled {
+		r.dropped++
+		r.nextProbe = time.Now().Add(r.probeInterval)
+		return len(p), nil
+	}
+	r.failures++
+	if r.failures >= r.maxFailures {
+		fmt.Fprintf(os.Stderr, "logger output failed %d times in a row, dropping records: %v\n", r.failures, err)
+		r.disabled = true
+		r.dropped++
+		r.nextProbe = time.Now().Add(r.probeInterval)
+		return len(p), nil
+	}
+	return n, err
+}
+
+func (r *ResilientWriteSyncer) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.disabled {
+		return nil
+	}
+	return r.ws.Sync()
+}
+
+// Degraded сообщает, отбрасываются ли сейчас записи.
+func (r *ResilientWriteSyncer) Degraded() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.disabled
+}
@@ -0,0 +1,110 @@
+package zap_engine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyWriter падает на записи, пока выставлен fail
+type flakyWriter struct {
+	mu     sync.Mutex
+	fail   bool
+	writes int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes++
+	if w.fail {
+		return 0, errors.New("broken pipe")
+	}
+	return len(p), nil
+}
+
+func (w *flakyWriter) Sync() error { return nil }
+
+func (w *flakyWriter) set(fail bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fail = fail
+}
+
+func (w *flakyWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writes
+}
+
+func TestResilientWriteSyncerSwitchesOverAndRecovers(t *testing.T) {
+	w := &flakyWriter{fail: true}
+	const probe = 50 * time.Millisecond
+	r := NewResilientWriteSyncer(w, 3, probe)
+	rec := []byte("record\n")
+
+	for i := 1; i < 3; i++ {
+		if _, err := r.Write(rec); err == nil {
+			t.Fatalf("write %d: error swallowed before the threshold", i)
+		}
+		if r.Degraded() {
+			t.Fatalf("degraded after %d failures, threshold is 3", i)
+		}
+	}
+	if n, err := r.Write(rec); err != nil || n != len(rec) {
+		t.Fatalf("threshold write: n=%d err=%v, want silent drop", n, err)
+	}
+	if !r.Degraded() {
+		t.Fatal("not degraded after 3 consecutive failures")
+	}
+
+	// до пробы записи не доходят до сломанного вывода
+	before := w.count()
+	for i := 0; i < 10; i++ {
+		if _, err := r.Write(rec); err != nil {
+			t.Fatalf("degraded write returned %v", err)
+		}
+	}
+	if got := w.count(); got != before {
+		t.Fatalf("underlying writer hit %d times while degraded", got-before)
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync while degraded: %v", err)
+	}
+
+	// неудачная проба оставляет режим отбрасывания
+	time.Sleep(probe + 10*time.Millisecond)
+	if _, err := r.Write(rec); err != nil || !r.Degraded() {
+		t.Fatalf("failed probe: err=%v degraded=%v", err, r.Degraded())
+	}
+
+	w.set(false)
+	time.Sleep(probe + 10*time.Millisecond)
+	before = w.count()
+	if _, err := r.Write(rec); err != nil {
+		t.Fatalf("probe write: %v", err)
+	}
+	if r.Degraded() || w.count() != before+1 {
+		t.Fatalf("not recovered after a successful probe: degraded=%v", r.Degraded())
+	}
+	if _, err := r.Write(rec); err != nil || w.count() != before+2 {
+		t.Fatalf("writes not passed through after recovery: err=%v", err)
+	}
+}
+
+func TestResilientWriteSyncerSuccessResetsFailures(t *testing.T) {
+	w := &flakyWriter{fail: true}
+	r := NewResilientWriteSyncer(w, 2, time.Hour)
+
+	_, _ = r.Write([]byte("x"))
+	w.set(false)
+	_, _ = r.Write([]byte("x"))
+	w.set(true)
+	if _, err := r.Write([]byte("x")); err == nil {
+		t.Fatal("failure counter not reset by a successful write")
+	}
+	if r.Degraded() {
+		t.Fatal("degraded although failures were not consecutive")
+	}
+}
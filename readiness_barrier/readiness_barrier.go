@@ -16,6 +16,11 @@ const (
 
 type ReadinessBarrierConfig struct {
 	Name string
+	// FailureThreshold сколько not_ready подряд нужно, чтобы из ready перейти в not_ready.
+	// Гасит разовые сбои health-check, из-за которых балансер дёргает инстанс. 0 и 1 — сразу.
+	FailureThreshold int
+	// SuccessThreshold сколько ready подряд нужно, чтобы из not_ready перейти в ready. 0 и 1 — сразу.
+	SuccessThreshold int
 }
 
 type ReadinessBarrier struct {
@@ -109,6 +114,8 @@ func (r *ReadinessBarrier) setNotReady() {
 }
 
 func (r *ReadinessBarrier) listen(ctx context.Context) {
+	// счётчики подряд идущих сигналов, живут только в этой горутине
+	successes, failures := 0, 0
 	for {
 		select {
 		case <-ctx.Done():
@@ -116,9 +123,17 @@ func (r *ReadinessBarrier) listen(ctx context.Context) {
 		case sig := <-r.signals:
 			switch sig {
 			case ReadySignalToggle:
-				r.setReady()
+				failures = 0
+				successes++
+				if successes >= r.config.SuccessThreshold {
+					r.setReady()
+				}
 			case NotReadySignalToggle:
-				r.setNotReady()
+				successes = 0
+				failures++
+				if failures >= r.config.FailureThreshold {
+					r.setNotReady()
+				}
 			}
 		}
 	}
@@ -0,0 +1,80 @@
+package readiness_barrier
+
+import (
+	"context"
+	"testing"
+)
+
+// startSynced запускает барьер с небуферизованным каналом сигналов: отправка завершается,
+// только когда listen забрал сигнал, значит предыдущий к этому моменту уже применён
+func startSynced(t *testing.T, cfg ReadinessBarrierConfig) *ReadinessBarrier {
+	t.Helper()
+	r := NewReadinessBarrier(context.Background(), cfg)
+	r.signals = make(chan toggleSignal)
+	r.Start()
+	t.Cleanup(r.Stop)
+	return r
+}
+
+// send отправляет сигналы и дожидается их применения
+func send(t *testing.T, r *ReadinessBarrier, sigs ...toggleSignal) {
+	t.Helper()
+	// пустой сигнал listen игнорирует, он только подтверждает, что последний настоящий обработан
+	for _, sig := range append(sigs, "") {
+		if err := r.SendSignalCtx(context.Background(), sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFailureThresholdIgnoresSingleFailure(t *testing.T) {
+	r := startSynced(t, ReadinessBarrierConfig{Name: "db", FailureThreshold: 3})
+
+	send(t, r, ReadySignalToggle)
+	if !r.IsReady() {
+		t.Fatal("not ready after the first success")
+	}
+	send(t, r, NotReadySignalToggle)
+	if !r.IsReady() {
+		t.Fatal("a single failure flipped readiness")
+	}
+	// успех сбрасывает серию неудач
+	send(t, r, NotReadySignalToggle, ReadySignalToggle, NotReadySignalToggle, NotReadySignalToggle)
+	if !r.IsReady() {
+		t.Fatal("non-consecutive failures flipped readiness")
+	}
+	send(t, r, NotReadySignalToggle)
+	if r.IsReady() {
+		t.Fatal("still ready after 3 consecutive failures")
+	}
+}
+
+func TestSuccessThresholdRequiresConsecutiveSuccesses(t *testing.T) {
+	r := startSynced(t, ReadinessBarrierConfig{Name: "db", SuccessThreshold: 2})
+
+	send(t, r, ReadySignalToggle)
+	if r.IsReady() {
+		t.Fatal("ready after 1 of 2 successes")
+	}
+	send(t, r, NotReadySignalToggle, ReadySignalToggle)
+	if r.IsReady() {
+		t.Fatal("ready although the successes were not consecutive")
+	}
+	send(t, r, ReadySignalToggle)
+	if !r.IsReady() {
+		t.Fatal("not ready after 2 consecutive successes")
+	}
+}
+
+func TestZeroThresholdsFlipImmediately(t *testing.T) {
+	r := startSynced(t, ReadinessBarrierConfig{Name: "db"})
+
+	send(t, r, ReadySignalToggle)
+	if !r.IsReady() {
+		t.Fatal("not ready after a success")
+	}
+	send(t, r, NotReadySignalToggle)
+	if r.IsReady() {
+		t.Fatal("still ready after a failure")
+	}
+}
@@ -3,25 +3,76 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/PavelAgarkov/service-pkg/logger"
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
 	"github.com/robfig/cron/v3"
 )
 
+var cronParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
 type Cron struct {
-	c *cron.Cron
+	c               *cron.Cron
+	timeoutFraction float64
+
+	// runCtx отменяется в Stop, чтобы запущенные задачи увидели остановку через ctx.Done()
+	mu        sync.Mutex
+	runCtx    context.Context
+	runCancel context.CancelFunc
+}
+
+type CronOption func(*Cron)
+
+// WithTimeoutFraction ограничивает каждый запуск долей интервала до следующего срабатывания:
+// при fraction=0.9 и интервале 10s контекст задачи отменится через 9s, чтобы запуски не накладывались.
+// fraction <= 0 или > 1 — без ограничения.
+func WithTimeoutFraction(fraction float64) CronOption {
+	return func(c *Cron) {
+		c.timeoutFraction = fraction
+	}
 }
 
-func NewCron() *Cron {
-	return &Cron{
-		c: cron.New(cron.WithSeconds()),
+func NewCron(opts ...CronOption) *Cron {
+	c := &Cron{
+		c: cron.New(cron.WithParser(cronParser)),
+	}
+	c.runCtx, c.runCancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // Add "*/10 * * * * *" - каждые 10 секунд
+// fn получает контекст, производный от ctx (значения сохраняются), который дополнительно отменяется в Stop.
+// У каждого запуска в полях логгера свой run_id (RunIDField).
 func (c *Cron) Add(ctx context.Context, calendar string, fn func(ctx context.Context) error) {
-	_, err := c.c.AddFunc(calendar, func() {
+	schedule, err := cronParser.Parse(calendar)
+	if err != nil {
+		panic(fmt.Sprintf("failed to add cron job %s: %v", calendar, err))
+	}
+
+	c.c.Schedule(schedule, cron.FuncJob(func() {
+		ctx, cancel := c.invocationContext(withRunID(ctx), schedule)
+		defer cancel()
+		defer context.AfterFunc(c.running(), cancel)()
+		// панику ловим сами: recover раннера robfig/cron не пишет в наш логгер и теряет поля ctx
+		defer func() {
+			if r := recover(); r != nil {
+				logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
+					Msg:       "cron job panic",
+					Component: "cron",
+					Method:    "Add",
+					Args:      calendar,
+					Error:     fmt.Errorf("panic in cron job %s: %v", calendar, r),
+				})
+			}
+		}()
+
 		if err := fn(ctx); err != nil {
 			logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
 				Msg:       "cron job failed",
@@ -31,16 +82,36 @@ func (c *Cron) Add(ctx context.Context, calendar string, fn func(ctx context.Con
 				Error:     err,
 			})
 		}
-	})
-	if err != nil {
-		panic(fmt.Sprintf("failed to add cron job %s: %v", calendar, err))
+	}))
+}
+
+func (c *Cron) invocationContext(ctx context.Context, schedule cron.Schedule) (context.Context, context.CancelFunc) {
+	if c.timeoutFraction <= 0 || c.timeoutFraction > 1 {
+		return context.WithCancel(ctx)
 	}
+	now := time.Now()
+	interval := schedule.Next(now).Sub(now)
+	return context.WithTimeout(ctx, time.Duration(float64(interval)*c.timeoutFraction))
+}
+
+func (c *Cron) running() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.runCtx
 }
 
 func (c *Cron) Stop() {
+	c.mu.Lock()
+	c.runCancel()
+	c.mu.Unlock()
 	c.c.Stop()
 }
 
 func (c *Cron) Start() {
+	c.mu.Lock()
+	if c.runCtx.Err() != nil {
+		c.runCtx, c.runCancel = context.WithCancel(context.Background())
+	}
+	c.mu.Unlock()
 	c.c.Start()
 }
@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+// fixedSchedule календарное расписание с заранее известным следующим запуском
+type fixedSchedule struct{ at time.Time }
+
+func (f fixedSchedule) Next(time.Time) time.Time { return f.at }
+
+func TestInvocationTimeoutIsFractionOfInterval(t *testing.T) {
+	c := NewCron(WithTimeoutFraction(0.5))
+	start := time.Now()
+	ctx, cancel := c.invocationContext(context.Background(), fixedSchedule{at: start.Add(time.Second)})
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("invocation context has no deadline")
+	}
+	if d := deadline.Sub(start); d < 450*time.Millisecond || d > 550*time.Millisecond {
+		t.Fatalf("timeout %s, want about 500ms", d)
+	}
+}
+
+func TestInvocationWithoutFractionHasNoDeadline(t *testing.T) {
+	for _, fraction := range []float64{0, 1.5} {
+		c := NewCron(WithTimeoutFraction(fraction))
+		ctx, cancel := c.invocationContext(context.Background(), fixedSchedule{at: time.Now().Add(time.Second)})
+		if _, ok := ctx.Deadline(); ok {
+			t.Fatalf("fraction %v: unexpected deadline", fraction)
+		}
+		cancel()
+	}
+}
+
+func TestCronCancelsLongRunningJobBeforeNextFire(t *testing.T) {
+	c := NewCron(WithTimeoutFraction(0.5))
+	type result struct {
+		err     error
+		elapsed time.Duration
+	}
+	results := make(chan result, 1)
+	c.Add(context.Background(), "@every 1s", func(ctx context.Context) error {
+		start := time.Now()
+		<-ctx.Done()
+		select {
+		case results <- result{ctx.Err(), time.Since(start)}:
+		default:
+		}
+		return nil
+	})
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case r := <-results:
+		if !errors.Is(r.err, context.DeadlineExceeded) {
+			t.Fatalf("job ctx error %v, want DeadlineExceeded", r.err)
+		}
+		if r.elapsed >= time.Second {
+			t.Fatalf("job cancelled after %s, not before the next fire", r.elapsed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("job was not cancelled")
+	}
+}
+
+type ctxKey struct{}
+
+func TestCronStopCancelsRunningJob(t *testing.T) {
+	c := NewCron()
+	started := make(chan struct{}, 1)
+	type result struct {
+		err   error
+		value any
+	}
+	results := make(chan result, 1)
+	parent := context.WithValue(context.Background(), ctxKey{}, "kept")
+	c.Add(parent, "* * * * * *", func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+			return nil
+		}
+		<-ctx.Done()
+		results <- result{ctx.Err(), ctx.Value(ctxKey{})}
+		return nil
+	})
+	c.Start()
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job did not start")
+	}
+	c.Stop()
+
+	select {
+	case r := <-results:
+		if !errors.Is(r.err, context.Canceled) {
+			t.Fatalf("job ctx error %v, want Canceled", r.err)
+		}
+		if r.value != "kept" {
+			t.Fatalf("outer ctx value %v lost", r.value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("running job was not cancelled by Stop")
+	}
+}
+
+func TestCronRecoversPanicAndKeepsFiring(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	c := NewCron()
+	var calls atomic.Int32
+	fired := make(chan int32, 4)
+	c.Add(context.Background(), "* * * * * *", func(context.Context) error {
+		n := calls.Add(1)
+		if n == 1 {
+			panic("boom")
+		}
+		select {
+		case fired <- n:
+		default:
+		}
+		return nil
+	})
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case <-fired:
+	case <-time.After(3 * time.Second):
+		t.Fatal("no fire after the panicking one")
+	}
+
+	entries := logs.WithMessage("cron job panic")
+	if len(entries) != 1 {
+		t.Fatalf("%d panic entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e["args"] != "* * * * * *" || e["component"] != "cron" {
+		t.Fatalf("entry %v, want the calendar in args", e)
+	}
+	if msg, _ := e["error"].(string); !strings.Contains(msg, "boom") {
+		t.Fatalf("error %q does not carry the panic value", msg)
+	}
+	if e[RunIDField] == nil {
+		t.Fatalf("entry %v has no run id", e)
+	}
+}
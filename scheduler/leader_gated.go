@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+	"github.com/PavelAgarkov/service-pkg/watchdog"
+)
+
+// LeaderGatedScheduler запускает планировщики только на лидере:
+// TakenAcquire из watcher стартует их через TaskSupervisor, LostAcquire останавливает.
+// Закрытие watcher или отмена контекста тоже останавливают задачи.
+type LeaderGatedScheduler struct {
+	mu         sync.Mutex
+	supervisor *TaskSupervisor
+	watcher    <-chan int
+	running    bool
+	done       chan struct{}
+}
+
+func NewLeaderGatedScheduler(watcher <-chan int, schedulers ...JobSchedulerInterface) *LeaderGatedScheduler {
+	return &LeaderGatedScheduler{
+		supervisor: NewTaskSupervisor(schedulers),
+		watcher:    watcher,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start начинает слушать watcher и возвращает функцию остановки,
+// которая гасит задачи и дожидается выхода слушателя. Вызывать один раз.
+func (g *LeaderGatedScheduler) Start(ctx context.Context) func() {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// WithoutCancel: слушатель должен стартовать всегда, иначе done не закроется
+	utils.GoRecover(context.WithoutCancel(ctx), func(context.Context) {
+		defer close(g.done)
+		defer g.stop(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-g.watcher:
+				if !ok {
+					return
+				}
+				switch event {
+				case watchdog.TakenAcquire:
+					g.start(ctx)
+				case watchdog.LostAcquire:
+					g.stop(ctx)
+				}
+			}
+		}
+	})
+
+	return func() {
+		cancel()
+		<-g.done
+	}
+}
+
+// Running сообщает, запущены ли сейчас задачи (то есть держим ли мы лидерство).
+func (g *LeaderGatedScheduler) Running() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.running
+}
+
+func (g *LeaderGatedScheduler) start(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running {
+		return
+	}
+	g.supervisor.Start(ctx)
+	g.running = true
+	logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+		Msg:       "Leadership taken, jobs started",
+		Component: "scheduler",
+		Method:    "LeaderGatedScheduler",
+	})
+}
+
+func (g *LeaderGatedScheduler) stop(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.running {
+		return
+	}
+	g.supervisor.Stop()
+	g.running = false
+	logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+		Msg:       "Leadership lost, jobs stopped",
+		Component: "scheduler",
+		Method:    "LeaderGatedScheduler",
+	})
+}
@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/watchdog"
+)
+
+func waitRunning(t *testing.T, g *LeaderGatedScheduler, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for g.Running() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Running() = %v, want %v", g.Running(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitRuns(t *testing.T, runs *atomic.Int64, above int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() <= above {
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not run (runs %d)", runs.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLeaderGatedSchedulerFollowsLeadership(t *testing.T) {
+	var runs atomic.Int64
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "singleton", Tick: 5 * time.Millisecond, Func: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := make(chan int)
+	g := NewLeaderGatedScheduler(watcher, s)
+	stop := g.Start(context.Background())
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != 0 || g.Running() {
+		t.Fatalf("jobs ran without leadership (runs %d)", runs.Load())
+	}
+
+	watcher <- watchdog.TakenAcquire
+	waitRunning(t, g, true)
+	waitRuns(t, &runs, 0)
+
+	watcher <- watchdog.LostAcquire
+	waitRunning(t, g, false)
+	after := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if runs.Load() != after {
+		t.Fatalf("jobs kept running after leadership was lost (%d -> %d)", after, runs.Load())
+	}
+
+	watcher <- watchdog.TakenAcquire
+	waitRunning(t, g, true)
+	waitRuns(t, &runs, after)
+}
+
+func TestLeaderGatedSchedulerStopsOnClosedWatcher(t *testing.T) {
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "singleton", Tick: time.Hour, Func: func(context.Context) error { return nil }}); err != nil {
+		t.Fatal(err)
+	}
+	watcher := make(chan int)
+	g := NewLeaderGatedScheduler(watcher, s)
+	stop := g.Start(context.Background())
+
+	watcher <- watchdog.TakenAcquire
+	waitRunning(t, g, true)
+	close(watcher)
+	waitRunning(t, g, false)
+	stop()
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+	"github.com/PavelAgarkov/service-pkg/watchdog"
+)
+
+// LeadershipGate в отличие от LeaderGatedScheduler ничего не останавливает: расписания JobScheduler и Cron
+// продолжают тикать, а обёрнутые через Wrap функции просто не выполняются, пока мы не лидер.
+// Закрыт до первого TakenAcquire.
+type LeadershipGate struct {
+	open    atomic.Bool
+	skipped atomic.Int64
+}
+
+func NewLeadershipGate() *LeadershipGate {
+	return &LeadershipGate{}
+}
+
+// Follow переключает гейт по событиям watcher: TakenAcquire открывает, LostAcquire и GaveUp закрывают.
+// Закрытие watcher или отмена ctx тоже закрывают гейт.
+func (g *LeadershipGate) Follow(ctx context.Context, watcher <-chan int) {
+	utils.GoRecover(ctx, func(ctx context.Context) {
+		defer g.open.Store(false)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher:
+				if !ok {
+					return
+				}
+				switch event {
+				case watchdog.TakenAcquire:
+					g.open.Store(true)
+				case watchdog.LostAcquire, watchdog.GaveUp:
+					g.open.Store(false)
+				}
+			}
+		}
+	})
+}
+
+// Open открыт ли гейт, то есть лидеры ли мы сейчас.
+func (g *LeadershipGate) Open() bool {
+	return g.open.Load()
+}
+
+// Skipped сколько запусков пропущено из-за закрытого гейта.
+func (g *LeadershipGate) Skipped() int64 {
+	return g.skipped.Load()
+}
+
+// Wrap обёртка для JobConfiguration.Func и Cron.Add: при закрытом гейте запуск пропускается без ошибки.
+func (g *LeadershipGate) Wrap(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !g.open.Load() {
+			g.skipped.Add(1)
+			logger.WriteDebugLog(ctx, &logger_wrapper.LogEntry{
+				Msg:       "execution skipped, not a leader",
+				Component: "scheduler",
+				Method:    "LeadershipGate",
+			})
+			return nil
+		}
+		return fn(ctx)
+	}
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/watchdog"
+)
+
+func waitGate(t *testing.T, g *LeadershipGate, open bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for g.Open() != open {
+		if time.Now().After(deadline) {
+			t.Fatalf("Open() = %v, want %v", g.Open(), open)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitSkipped(t *testing.T, g *LeadershipGate, above int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for g.Skipped() <= above {
+		if time.Now().After(deadline) {
+			t.Fatalf("no skipped executions (skipped %d)", g.Skipped())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLeadershipGateSuppressesAndResumesExecutions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gate := NewLeadershipGate()
+	watcher := make(chan int, 1)
+	gate.Follow(ctx, watcher)
+
+	var runs atomic.Int64
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "singleton", Tick: 5 * time.Millisecond, Func: gate.Wrap(func(context.Context) error {
+		runs.Add(1)
+		return nil
+	})}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(ctx)()
+	defer s.Stop()()
+
+	// до первого TakenAcquire гейт закрыт: тики идут, запусков нет
+	waitSkipped(t, gate, 0)
+	if runs.Load() != 0 {
+		t.Fatalf("%d runs before leadership", runs.Load())
+	}
+
+	watcher <- watchdog.TakenAcquire
+	waitGate(t, gate, true)
+	waitRuns(t, &runs, 0)
+
+	watcher <- watchdog.LostAcquire
+	waitGate(t, gate, false)
+	// запуск, начавшийся до закрытия, мог успеть досчитать
+	time.Sleep(10 * time.Millisecond)
+	stopped := runs.Load()
+	skipped := gate.Skipped()
+	waitSkipped(t, gate, skipped)
+	if runs.Load() != stopped {
+		t.Fatalf("runs grew from %d to %d while gated", stopped, runs.Load())
+	}
+
+	watcher <- watchdog.TakenAcquire
+	waitGate(t, gate, true)
+	waitRuns(t, &runs, stopped)
+}
+
+func TestLeadershipGateClosesOnGaveUpAndClosedWatcher(t *testing.T) {
+	gate := NewLeadershipGate()
+	watcher := make(chan int, 1)
+	gate.Follow(context.Background(), watcher)
+
+	watcher <- watchdog.TakenAcquire
+	waitGate(t, gate, true)
+	watcher <- watchdog.GaveUp
+	waitGate(t, gate, false)
+
+	watcher <- watchdog.TakenAcquire
+	waitGate(t, gate, true)
+	close(watcher)
+	waitGate(t, gate, false)
+
+	var ran bool
+	if err := gate.Wrap(func(context.Context) error { ran = true; return nil })(context.Background()); err != nil || ran {
+		t.Fatalf("closed gate: err %v, ran %v", err, ran)
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PavelAgarkov/service-pkg/logger"
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
 	"github.com/PavelAgarkov/service-pkg/utils"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/xid"
 )
 
 type StopMode int
@@ -19,12 +23,34 @@ const (
 	StopGraceful
 )
 
+const DefaultMaxIterationsPerTick = 100
+
+// RunIDField поле лога с ID запуска: у всех записей одного запуска задачи JobScheduler или Cron
+// (через ctx, переданный в Func) один и тот же run_id, у параллельных запусков — разные.
+const RunIDField = "run_id"
+
+// ErrMoreWork возвращается из Func, если очередь ещё не разобрана:
+// планировщик сразу запускает задачу повторно, не дожидаясь следующего тика.
+var ErrMoreWork = errors.New("scheduler: more work")
+
 type JobConfiguration struct {
-	Name     string
-	Func     func(context.Context) error
-	Tick     time.Duration
+	Name string
+	Func func(context.Context) error
+	Tick time.Duration
+	// Schedule календарное расписание в формате Cron ("0 0 2 * * *" — каждый день в 02:00, с секундами)
+	// вместо Tick. Если задано, Tick не используется.
+	Schedule string
+	// Deadline устаревшее общее значение: используется для ExecTimeout и GracefulStopTimeout, если они не заданы.
 	Deadline time.Duration
-	StopMode StopMode
+	// ExecTimeout таймаут одного запуска. 0 — без ограничения.
+	ExecTimeout time.Duration
+	// GracefulStopTimeout сколько при остановке ждать уже идущий запуск StopGraceful задачи,
+	// после чего её контекст отменяется. 0 — ждать до ExecTimeout.
+	GracefulStopTimeout time.Duration
+	StopMode            StopMode
+	// MaxIterationsPerTick ограничивает число немедленных перезапусков по ErrMoreWork за один тик.
+	// 0 — DefaultMaxIterationsPerTick.
+	MaxIterationsPerTick int
 }
 
 type job struct {
@@ -35,9 +61,25 @@ type job struct {
 	fn       func(context.Context) error
 	tick     time.Duration
 	ticker   *time.Ticker
-	deadline time.Duration
+	schedule cron.Schedule // nil — интервальная задача
+	now      func() time.Time
 	wg       sync.WaitGroup
 	stopMode StopMode
+
+	maxIterationsPerTick int
+	execTimeout          time.Duration
+	gracefulStopTimeout  time.Duration
+
+	// текущий запуск: время старта и отмена только его контекста
+	execMu     sync.Mutex
+	execSince  time.Time
+	execCancel context.CancelFunc
+}
+
+// RunningJob задача, которая выполняется прямо сейчас
+type RunningJob struct {
+	Name  string
+	Since time.Time
 }
 
 type JobScheduler struct {
@@ -45,15 +87,53 @@ type JobScheduler struct {
 	started    bool
 	goroutines map[string]*job
 	rate       chan struct{}
+	now        func() time.Time
+
+	rateWaits    atomic.Int64
+	rateWaitTime atomic.Int64 // наносекунды
+}
+
+// RateStats снимок загрузки rate-лимитера. Если Waits растёт постоянно, rate мал для набора задач.
+type RateStats struct {
+	InFlight int
+	Capacity int
+	// Waits сколько запусков ждали свободный слот (накопительно)
+	Waits int64
+	// WaitTime суммарное время ожидания слота
+	WaitTime time.Duration
+}
+
+type JobSchedulerOption func(*JobScheduler)
+
+// WithClock подменяет источник времени для календарных задач (Schedule), например locker.ManualClock.Now в тестах.
+func WithClock(now func() time.Time) JobSchedulerOption {
+	return func(s *JobScheduler) {
+		s.now = now
+	}
 }
 
-func NewJobScheduler(rate int64) *JobScheduler {
+func NewJobScheduler(rate int64, opts ...JobSchedulerOption) *JobScheduler {
 	if rate <= 0 {
 		rate = 1
 	}
-	return &JobScheduler{
+	s := &JobScheduler{
 		rate:       make(chan struct{}, rate),
 		goroutines: make(map[string]*job),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *JobScheduler) RateStats() RateStats {
+	rate := s.limiter()
+	return RateStats{
+		InFlight: len(rate),
+		Capacity: cap(rate),
+		Waits:    s.rateWaits.Load(),
+		WaitTime: time.Duration(s.rateWaitTime.Load()),
 	}
 }
 
@@ -68,12 +148,41 @@ func (s *JobScheduler) Add(cfg JobConfiguration) error {
 		return fmt.Errorf("scheduler.Add(%s): job already exists", cfg.Name)
 	}
 
+	var schedule cron.Schedule
+	if cfg.Schedule != "" {
+		var err error
+		if schedule, err = cronParser.Parse(cfg.Schedule); err != nil {
+			return fmt.Errorf("scheduler.Add(%s): invalid schedule %q: %w", cfg.Name, cfg.Schedule, err)
+		}
+		// robfig/cron отдаёт нулевое время для расписания, которое не сработает никогда (30 февраля)
+		if schedule.Next(s.now()).IsZero() {
+			return fmt.Errorf("scheduler.Add(%s): schedule %q never fires", cfg.Name, cfg.Schedule)
+		}
+	} else if cfg.Tick <= 0 {
+		return fmt.Errorf("scheduler.Add(%s): either Tick or Schedule is required", cfg.Name)
+	}
+
+	if cfg.MaxIterationsPerTick <= 0 {
+		cfg.MaxIterationsPerTick = DefaultMaxIterationsPerTick
+	}
+	if cfg.ExecTimeout <= 0 {
+		cfg.ExecTimeout = cfg.Deadline
+	}
+	if cfg.GracefulStopTimeout <= 0 {
+		cfg.GracefulStopTimeout = cfg.Deadline
+	}
+
 	s.goroutines[cfg.Name] = &job{
 		name:     cfg.Name,
 		fn:       cfg.Func,
 		tick:     cfg.Tick,
-		deadline: cfg.Deadline,
+		schedule: schedule,
+		now:      s.now,
 		stopMode: cfg.StopMode,
+
+		maxIterationsPerTick: cfg.MaxIterationsPerTick,
+		execTimeout:          cfg.ExecTimeout,
+		gracefulStopTimeout:  cfg.GracefulStopTimeout,
 	}
 	return nil
 }
@@ -103,7 +212,9 @@ func (s *JobScheduler) Start(ctx context.Context) func() {
 		for name, j := range jobs {
 			j.rmu.Lock()
 			j.ctx, j.cancel = context.WithCancel(ctx)
-			j.ticker = time.NewTicker(j.tick)
+			if j.schedule == nil {
+				j.ticker = time.NewTicker(j.tick)
+			}
 			j.wg.Add(1)
 			j.rmu.Unlock()
 
@@ -111,40 +222,198 @@ func (s *JobScheduler) Start(ctx context.Context) func() {
 				s.run(name, j)
 			})
 		}
+		logger.LogLifecycle(ctx, "scheduler", logger.LifecycleStarted, logger.WithField("jobs", len(jobs)))
+	}
+}
+
+// RunOnce выполняет каждую задачу один раз без тикеров и возвращает все ошибки вместе.
+// Для batch-запусков (Kubernetes CronJob), когда бинарь должен отработать и выйти.
+// Rate-лимит, таймауты и StopMode соблюдаются так же, как при обычном запуске, ErrMoreWork дорабатывается сразу.
+func (s *JobScheduler) RunOnce(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return fmt.Errorf("scheduler.RunOnce: already started")
+	}
+	s.started = true
+	jobs := make([]*job, 0, len(s.goroutines))
+	for _, j := range s.goroutines {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.started = false
+		s.mu.Unlock()
+	}()
+
+	var (
+		wg   sync.WaitGroup
+		emu  sync.Mutex
+		errs []error
+	)
+	for _, j := range jobs {
+		j.rmu.Lock()
+		j.ctx, j.cancel = context.WithCancel(ctx)
+		j.rmu.Unlock()
+
+		wg.Add(1)
+		// WithoutCancel: горутина должна стартовать всегда, иначе wg.Done не вызовется
+		utils.GoRecover(context.WithoutCancel(ctx), func(context.Context) {
+			defer wg.Done()
+			defer j.cancel()
+			if err := s.drain(withRunID(j.ctx), j); err != nil {
+				emu.Lock()
+				errs = append(errs, fmt.Errorf("job %s: %w", j.name, err))
+				emu.Unlock()
+			}
+		})
 	}
+	wg.Wait()
+
+	return errors.Join(errs...)
 }
 
 // Stop останавливает задачи и дожидается их завершения.
 func (s *JobScheduler) Stop() func() {
 	return func() {
-		s.mu.Lock()
-		if !s.started {
-			s.mu.Unlock()
+		jobs := s.cancelJobs()
+		if jobs == nil {
 			return
 		}
-		s.started = false
+		logger.LogLifecycle(context.Background(), "scheduler", logger.LifecycleStopping, logger.WithField("jobs", len(jobs)))
+		for _, j := range jobs {
+			j.wg.Wait()
+		}
+		logger.LogLifecycle(context.Background(), "scheduler", logger.LifecycleStopped)
+	}
+}
 
-		// отзываем контексты + останавливаем тикеры под защитой локов каждого job
-		for _, j := range s.goroutines {
-			j.cancel()
-			j.rmu.Lock()
-			if j.ticker != nil {
-				j.ticker.Stop()
-			}
-			j.rmu.Unlock()
+// StopCtx останавливает задачи и ждёт их завершения, но не дольше ctx.
+// Нужен для StopGraceful задач, которые игнорируют отмену родительского контекста
+// и могут подвесить остановку. Незавершившиеся задачи логируются и возвращаются в ошибке.
+func (s *JobScheduler) StopCtx(ctx context.Context) error {
+	jobs := s.cancelJobs()
+	if jobs == nil {
+		return nil
+	}
+	logger.LogLifecycle(ctx, "scheduler", logger.LifecycleStopping, logger.WithField("jobs", len(jobs)))
+
+	done := make(map[string]chan struct{}, len(jobs))
+	for _, j := range jobs {
+		ch := make(chan struct{})
+		done[j.name] = ch
+		go func(j *job) {
+			j.wg.Wait()
+			close(ch)
+		}(j)
+	}
+
+	unfinished := make([]string, 0)
+	for name, ch := range done {
+		select {
+		case <-ch:
+			continue
+		case <-ctx.Done():
+		}
+		select {
+		case <-ch:
+		default:
+			unfinished = append(unfinished, name)
 		}
+	}
+	if len(unfinished) == 0 {
+		logger.LogLifecycle(ctx, "scheduler", logger.LifecycleStopped)
+		return nil
+	}
+
+	sort.Strings(unfinished)
+	logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+		Msg:       "Jobs did not finish before stop deadline",
+		Component: "scheduler",
+		Method:    "StopCtx",
+		Args:      unfinished,
+	})
+	return fmt.Errorf("scheduler.StopCtx: jobs did not finish: %v: %w", unfinished, ctx.Err())
+}
+
+// StopForce останавливает задачи и ждёт их не дольше budget. Дальше зависшие запуски (в том числе StopGraceful
+// с длинным GracefulStopTimeout) получают отмену контекста, а rate-лимитер заменяется новым, чтобы занятые ими
+// слоты не мешали повторному Start. Горутины таких запусков завершатся сами, когда fn вернёт управление,
+// ждать их StopForce не будет. Ошибка — как у StopCtx.
+func (s *JobScheduler) StopForce(budget time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	err := s.StopCtx(ctx)
+	if err == nil {
+		return nil
+	}
 
-		// копия слайса, чтобы ждать уже без глобального лока
-		jobs := make([]*job, 0, len(s.goroutines))
-		for _, j := range s.goroutines {
-			jobs = append(jobs, j)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.goroutines {
+		j.execMu.Lock()
+		if j.execCancel != nil {
+			j.execCancel()
 		}
-		s.mu.Unlock()
+		j.execMu.Unlock()
+	}
+	s.rate = make(chan struct{}, cap(s.rate))
+	return err
+}
 
-		for _, j := range jobs {
-			j.wg.Wait()
+// UpdateTick меняет интервал задачи на лету, например чтобы реже ходить в перегруженный сервис.
+// Идущий запуск не прерывается, новый интервал отсчитывается с момента вызова. Работает и до Start.
+func (s *JobScheduler) UpdateTick(name string, newTick time.Duration) error {
+	if newTick <= 0 {
+		return fmt.Errorf("scheduler.UpdateTick(%s): tick must be positive, got %s", name, newTick)
+	}
+	s.mu.Lock()
+	j, ok := s.goroutines[name]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("scheduler.UpdateTick(%s): job not found", name)
+	}
+	if j.schedule != nil {
+		return fmt.Errorf("scheduler.UpdateTick(%s): job runs on a calendar schedule", name)
+	}
+
+	j.rmu.Lock()
+	defer j.rmu.Unlock()
+	j.tick = newTick
+	if j.ticker != nil {
+		j.ticker.Reset(newTick)
+	}
+	return nil
+}
+
+// cancelJobs отзывает контексты и гасит тикеры, возвращает job-ы, которых нужно дождаться.
+func (s *JobScheduler) cancelJobs() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started {
+		return nil
+	}
+	s.started = false
+
+	// отзываем контексты + останавливаем тикеры под защитой локов каждого job
+	for _, j := range s.goroutines {
+		j.cancel()
+		j.rmu.Lock()
+		if j.ticker != nil {
+			j.ticker.Stop()
 		}
+		j.rmu.Unlock()
 	}
+
+	// копия слайса, чтобы ждать уже без глобального лока
+	jobs := make([]*job, 0, len(s.goroutines))
+	for _, j := range s.goroutines {
+		jobs = append(jobs, j)
+	}
+	return jobs
 }
 
 func (s *JobScheduler) run(name string, j *job) {
@@ -156,8 +425,10 @@ func (s *JobScheduler) run(name string, j *job) {
 		ticker := j.ticker
 		j.rmu.RUnlock()
 
+		fire, stopWait := j.next(ticker)
 		select {
 		case <-ctx.Done():
+			stopWait()
 			logger.WriteInfoLog(j.ctx, &logger_wrapper.LogEntry{
 				Msg:       "Job stopped",
 				Component: "scheduler",
@@ -166,9 +437,11 @@ func (s *JobScheduler) run(name string, j *job) {
 			})
 			return
 
-		case <-ticker.C:
-			if err := s.exec(j); err != nil && !errors.Is(err, context.Canceled) {
-				logger.WriteErrorLog(j.ctx, &logger_wrapper.LogEntry{
+		case <-fire:
+			stopWait()
+			runCtx := withRunID(ctx)
+			if err := s.drain(runCtx, j); err != nil && !errors.Is(err, context.Canceled) {
+				logger.WriteErrorLog(runCtx, &logger_wrapper.LogEntry{
 					Msg:       "Job execution failed",
 					Component: "scheduler",
 					Method:    "run",
@@ -180,16 +453,59 @@ func (s *JobScheduler) run(name string, j *job) {
 	}
 }
 
-func (s *JobScheduler) exec(j *job) (err error) {
-	select {
-	case <-j.ctx.Done():
-		return j.ctx.Err()
-	case s.rate <- struct{}{}:
+// withRunID новый xid запуска в поля логгера ctx.
+func withRunID(ctx context.Context) context.Context {
+	return logger.WithContextField(ctx, RunIDField, xid.New().String())
+}
+
+// next канал следующего срабатывания: тикер для интервальной задачи, таймер до schedule.Next для календарной.
+// Вторым значением — освобождение таймера.
+func (j *job) next(ticker *time.Ticker) (<-chan time.Time, func()) {
+	if j.schedule == nil {
+		return ticker.C, func() {}
+	}
+	now := j.now()
+	at := j.schedule.Next(now)
+	if at.IsZero() {
+		// срабатываний больше не будет: nil-канал не сработает никогда, задача ждёт только остановки
+		logger.WriteWarnLog(j.ctx, &logger_wrapper.LogEntry{
+			Msg:       "Job schedule has no next run",
+			Component: "scheduler",
+			Method:    "next",
+			Args:      j.name,
+		})
+		return nil, func() {}
+	}
+	timer := time.NewTimer(at.Sub(now))
+	return timer.C, func() { timer.Stop() }
+}
+
+// drain выполняет задачу и перезапускает её сразу, пока она возвращает ErrMoreWork,
+// но не больше maxIterationsPerTick раз за тик.
+func (s *JobScheduler) drain(ctx context.Context, j *job) error {
+	for i := 0; i < j.maxIterationsPerTick; i++ {
+		err := s.exec(ctx, j)
+		if !errors.Is(err, ErrMoreWork) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// exec один запуск; ctx — контекст задачи с run_id (производный от j.ctx).
+func (s *JobScheduler) exec(ctx context.Context, j *job) (err error) {
+	rate, err := s.acquireRate(ctx)
+	if err != nil {
+		return err
 	}
-	defer func() { <-s.rate }()
+	// освобождаем именно тот лимитер, в котором заняли слот: StopForce может его заменить
+	defer func() { <-rate }()
 	defer func() {
 		if r := recover(); r != nil {
-			logger.WriteErrorLog(j.ctx, &logger_wrapper.LogEntry{
+			logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
 				Msg:       "Job panic",
 				Component: "scheduler",
 				Method:    "exec",
@@ -200,20 +516,131 @@ func (s *JobScheduler) exec(j *job) (err error) {
 		}
 	}()
 
-	if j.ctx.Err() != nil {
-		return j.ctx.Err()
+	if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
 	switch j.stopMode {
 	case StopImmediate:
-		ctx, cancel := context.WithTimeout(j.ctx, j.deadline)
+		ctx, cancel := withOptionalTimeout(ctx, j.execTimeout)
 		defer cancel()
+		defer j.track(cancel)()
 		err = j.fn(ctx)
 	case StopGraceful:
-		ctx, cancel := context.WithTimeout(context.Background(), j.deadline)
+		// отмена планировщика не доходит до запуска сразу: ему даётся ещё gracefulStopTimeout
+		parent := ctx
+		ctx, cancel := withOptionalTimeout(context.WithoutCancel(parent), j.execTimeout)
 		defer cancel()
+		if j.gracefulStopTimeout > 0 {
+			stop := context.AfterFunc(parent, func() {
+				timer := time.NewTimer(j.gracefulStopTimeout)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+					cancel()
+				case <-ctx.Done():
+				}
+			})
+			defer stop()
+		}
+		defer j.track(cancel)()
 		err = j.fn(ctx)
 	}
 
 	return err
 }
+
+// acquireRate занимает слот rate-лимитера и возвращает лимитер, в котором он занят; ожидание учитывается в RateStats.
+func (s *JobScheduler) acquireRate(ctx context.Context) (chan struct{}, error) {
+	rate := s.limiter()
+	select {
+	case rate <- struct{}{}:
+		return rate, nil
+	default:
+	}
+
+	s.rateWaits.Add(1)
+	start := time.Now()
+	defer func() { s.rateWaitTime.Add(int64(time.Since(start))) }()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case rate <- struct{}{}:
+		return rate, nil
+	}
+}
+
+func (s *JobScheduler) limiter() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate
+}
+
+// track отмечает запуск как текущий и возвращает функцию, снимающую отметку.
+func (j *job) track(cancel context.CancelFunc) func() {
+	j.execMu.Lock()
+	j.execSince = time.Now()
+	j.execCancel = cancel
+	j.execMu.Unlock()
+
+	return func() {
+		j.execMu.Lock()
+		j.execSince = time.Time{}
+		j.execCancel = nil
+		j.execMu.Unlock()
+	}
+}
+
+// Running возвращает задачи, которые выполняются в данный момент (не просто зарегистрированы), по имени.
+func (s *JobScheduler) Running() []RunningJob {
+	s.mu.Lock()
+	jobs := make([]*job, 0, len(s.goroutines))
+	for _, j := range s.goroutines {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	running := make([]RunningJob, 0)
+	for _, j := range jobs {
+		j.execMu.Lock()
+		if j.execCancel != nil {
+			running = append(running, RunningJob{Name: j.name, Since: j.execSince})
+		}
+		j.execMu.Unlock()
+	}
+	sort.Slice(running, func(a, b int) bool { return running[a].Name < running[b].Name })
+	return running
+}
+
+// CancelRunning отменяет контекст текущего запуска задачи name, не трогая её расписание:
+// следующий тик запустит её как обычно. false — задачи нет или она сейчас не выполняется.
+func (s *JobScheduler) CancelRunning(name string) bool {
+	s.mu.Lock()
+	j, ok := s.goroutines[name]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	j.execMu.Lock()
+	defer j.execMu.Unlock()
+	if j.execCancel == nil {
+		return false
+	}
+	j.execCancel()
+	logger.WriteWarnLog(context.Background(), &logger_wrapper.LogEntry{
+		Msg:       "Running job execution cancelled",
+		Component: "scheduler",
+		Method:    "CancelRunning",
+		Args:      name,
+	})
+	return true
+}
+
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,695 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	"github.com/PavelAgarkov/service-pkg/locker"
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+func TestAddRejectsScheduleThatNeverFires(t *testing.T) {
+	s := NewJobScheduler(1)
+	err := s.Add(JobConfiguration{Name: "feb30", Func: func(context.Context) error { return nil }, Schedule: "0 0 0 30 2 *"})
+	if err == nil {
+		t.Fatal("Add accepted a schedule that never fires")
+	}
+}
+
+func TestAddRequiresTickOrSchedule(t *testing.T) {
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "none", Func: func(context.Context) error { return nil }}); err == nil {
+		t.Fatal("Add accepted a job without Tick and Schedule")
+	}
+	if err := s.Add(JobConfiguration{Name: "bad", Func: func(context.Context) error { return nil }, Schedule: "not a cron"}); err == nil {
+		t.Fatal("Add accepted an invalid schedule")
+	}
+}
+
+// calendarJob календарная задача spec, добавленная через Add, с часами clock
+func calendarJob(t *testing.T, clock *locker.ManualClock, spec string) *job {
+	t.Helper()
+	s := NewJobScheduler(1, WithClock(clock.Now))
+	if err := s.Add(JobConfiguration{Name: "nightly", Func: func(context.Context) error { return nil }, Schedule: spec}); err != nil {
+		t.Fatal(err)
+	}
+	j := s.goroutines["nightly"]
+	j.ctx = context.Background()
+	return j
+}
+
+func TestCalendarNextRunFollowsCronSpec(t *testing.T) {
+	clock := locker.NewManualClock(time.Date(2026, 3, 10, 1, 59, 30, 0, time.Local))
+	j := calendarJob(t, clock, "0 0 2 * * *")
+
+	if at := j.schedule.Next(j.now()); !at.Equal(time.Date(2026, 3, 10, 2, 0, 0, 0, time.Local)) {
+		t.Fatalf("next run at %s, want 02:00:00 the same day", at)
+	}
+	clock.Advance(30 * time.Second)
+	if at := j.schedule.Next(j.now()); !at.Equal(time.Date(2026, 3, 11, 2, 0, 0, 0, time.Local)) {
+		t.Fatalf("run after 02:00:00 at %s, want 02:00:00 the following day", at)
+	}
+}
+
+func TestCalendarTimerWaitsUntilNextRunOnClock(t *testing.T) {
+	// до 02:00:00 по часам задачи остаётся 50ms
+	clock := locker.NewManualClock(time.Date(2026, 3, 10, 1, 59, 59, int(950*time.Millisecond), time.Local))
+	j := calendarJob(t, clock, "0 0 2 * * *")
+
+	fire, stop := j.next(nil)
+	defer stop()
+	start := time.Now()
+	select {
+	case <-fire:
+	case <-time.After(time.Second):
+		t.Fatal("calendar job did not fire at 02:00:00")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("fired after %s, want about 50ms", elapsed)
+	}
+
+	// сразу после запуска следующий — через сутки
+	clock.Advance(50 * time.Millisecond)
+	fire, stop = j.next(nil)
+	defer stop()
+	select {
+	case <-fire:
+		t.Fatal("calendar job fired again right after 02:00:00")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEveryDescriptorNextRunOnClock(t *testing.T) {
+	clock := locker.NewManualClock(time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local))
+	j := calendarJob(t, clock, "@every 90m")
+
+	if at := j.schedule.Next(j.now()); !at.Equal(time.Date(2026, 3, 10, 13, 30, 0, 0, time.Local)) {
+		t.Fatalf("next run at %s, want 13:30:00", at)
+	}
+}
+
+func TestNextNeverFiresOnZeroTime(t *testing.T) {
+	schedule, err := cronParser.Parse("0 0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &job{name: "never", ctx: context.Background(), schedule: schedule, now: time.Now}
+	fire, stop := j.next(nil)
+	defer stop()
+	if fire != nil {
+		t.Fatal("want nil channel for a schedule without next run")
+	}
+}
+
+func TestNextIntervalUsesTicker(t *testing.T) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	j := &job{name: "interval", ctx: context.Background()}
+	fire, stop := j.next(ticker)
+	defer stop()
+
+	select {
+	case <-fire:
+	case <-time.After(time.Second):
+		t.Fatal("interval job did not fire")
+	}
+}
+
+func TestScheduleAndTickJobsRun(t *testing.T) {
+	s := NewJobScheduler(2)
+	calendar := make(chan struct{}, 1)
+	interval := make(chan struct{}, 1)
+	notify := func(ch chan struct{}) func(context.Context) error {
+		return func(context.Context) error {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+			return nil
+		}
+	}
+	if err := s.Add(JobConfiguration{Name: "calendar", Func: notify(calendar), Schedule: "* * * * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(JobConfiguration{Name: "interval", Func: notify(interval), Tick: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	defer s.Stop()()
+
+	for name, ch := range map[string]chan struct{}{"calendar": calendar, "interval": interval} {
+		select {
+		case <-ch:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%s job did not run", name)
+		}
+	}
+}
+
+func TestConcurrentRunsHaveDistinctRunIDs(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	s := NewJobScheduler(2)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	fn := func(ctx context.Context) error {
+		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{Msg: "job step"})
+		started <- struct{}{}
+		<-release
+		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{Msg: "job step"})
+		return nil
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := s.Add(JobConfiguration{Name: name, Func: fn, Tick: time.Hour}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.RunOnce(context.Background()) }()
+	// обе задачи выполняются одновременно
+	<-started
+	<-started
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	perRun := map[string]int{}
+	for _, e := range logs.WithMessage("job step") {
+		id, _ := e[RunIDField].(string)
+		if id == "" {
+			t.Fatalf("log entry without %s: %v", RunIDField, e)
+		}
+		perRun[id]++
+	}
+	if len(perRun) != 2 {
+		t.Fatalf("want 2 distinct run ids, got %v", perRun)
+	}
+	for id, n := range perRun {
+		if n != 2 {
+			t.Fatalf("run %s has %d entries, want 2 (one run must share one id)", id, n)
+		}
+	}
+}
+
+func TestErrMoreWorkRerunsUntilQueueIsEmpty(t *testing.T) {
+	s := NewJobScheduler(1)
+	queue := 5
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		queue--
+		if queue > 0 {
+			return ErrMoreWork
+		}
+		return nil
+	}
+	if err := s.Add(JobConfiguration{Name: "queue", Func: fn, Tick: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 5 {
+		t.Fatalf("%d runs, want 5", calls)
+	}
+}
+
+func TestErrMoreWorkBoundedPerTick(t *testing.T) {
+	s := NewJobScheduler(1)
+	calls := 0
+	fn := func(context.Context) error {
+		calls++
+		return ErrMoreWork
+	}
+	if err := s.Add(JobConfiguration{Name: "endless", Func: fn, Tick: time.Hour, MaxIterationsPerTick: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RunOnce(context.Background()); err != nil {
+		t.Fatalf("ErrMoreWork leaked out of RunOnce: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("%d runs, want 3", calls)
+	}
+}
+
+func TestStopCtxReturnsOnDeadline(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	s := NewJobScheduler(1)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	fn := func(context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil
+	}
+	if err := s.Add(JobConfiguration{Name: "stuck", Func: fn, Tick: 10 * time.Millisecond, StopMode: StopGraceful}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	<-started
+	defer func() {
+		close(release)
+		s.goroutines["stuck"].wg.Wait()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := s.StopCtx(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("err %v, want DeadlineExceeded naming the stuck job", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("StopCtx returned after %s", elapsed)
+	}
+	if len(logs.WithMessage("Jobs did not finish before stop deadline")) != 1 {
+		t.Fatal("unfinished jobs were not logged")
+	}
+}
+
+func TestRunOnceRunsEachJobOnceAndJoinsErrors(t *testing.T) {
+	s := NewJobScheduler(2)
+	var mu sync.Mutex
+	runs := map[string]int{}
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	for name, err := range map[string]error{"a": errA, "b": errB, "ok": nil} {
+		fn := func(context.Context) error {
+			mu.Lock()
+			runs[name]++
+			mu.Unlock()
+			return err
+		}
+		if err := s.Add(JobConfiguration{Name: name, Func: fn, Tick: 10 * time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.RunOnce(context.Background())
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("err %v, want both job errors", err)
+	}
+	// тикеров нет: после RunOnce задачи больше не запускаются
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range []string{"a", "b", "ok"} {
+		if runs[name] != 1 {
+			t.Fatalf("job %s ran %d times, want 1", name, runs[name])
+		}
+	}
+}
+
+func TestRunOnceRejectsStartedScheduler(t *testing.T) {
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "a", Func: func(context.Context) error { return nil }, Tick: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	defer s.Stop()()
+	if err := s.RunOnce(context.Background()); err == nil {
+		t.Fatal("RunOnce on a started scheduler succeeded")
+	}
+}
+
+func TestExecTimeoutCancelsRun(t *testing.T) {
+	s := NewJobScheduler(1)
+	fn := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := s.Add(JobConfiguration{Name: "slow", Func: fn, Tick: time.Hour, ExecTimeout: 50 * time.Millisecond, GracefulStopTimeout: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := s.RunOnce(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("run lasted %s, ExecTimeout is 50ms", elapsed)
+	}
+}
+
+func TestGracefulStopWaitsOnlyGracefulStopTimeout(t *testing.T) {
+	s := NewJobScheduler(1)
+	started := make(chan struct{}, 1)
+	runErr := make(chan error, 1)
+	fn := func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		select {
+		case runErr <- ctx.Err():
+		default:
+		}
+		return nil
+	}
+	cfg := JobConfiguration{
+		Name:                "graceful",
+		Func:                fn,
+		Tick:                10 * time.Millisecond,
+		StopMode:            StopGraceful,
+		ExecTimeout:         time.Hour,
+		GracefulStopTimeout: 100 * time.Millisecond,
+	}
+	if err := s.Add(cfg); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	<-started
+
+	start := time.Now()
+	s.Stop()()
+	elapsed := time.Since(start)
+	if elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Stop took %s, want about GracefulStopTimeout (100ms)", elapsed)
+	}
+	if err := <-runErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("run ctx error %v, want Canceled by the graceful stop budget", err)
+	}
+}
+
+func TestDeadlineFillsBothTimeouts(t *testing.T) {
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "legacy", Func: func(context.Context) error { return nil }, Tick: time.Hour, Deadline: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(JobConfiguration{Name: "split", Func: func(context.Context) error { return nil }, Tick: time.Hour, Deadline: time.Minute, ExecTimeout: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if j := s.goroutines["legacy"]; j.execTimeout != time.Minute || j.gracefulStopTimeout != time.Minute {
+		t.Fatalf("legacy: exec %s, graceful %s; want Deadline for both", j.execTimeout, j.gracefulStopTimeout)
+	}
+	if j := s.goroutines["split"]; j.execTimeout != time.Second || j.gracefulStopTimeout != time.Minute {
+		t.Fatalf("split: exec %s, graceful %s", j.execTimeout, j.gracefulStopTimeout)
+	}
+}
+
+func TestRateStatsCountsWaitsWhenSaturated(t *testing.T) {
+	s := NewJobScheduler(1)
+	running := make(chan struct{}, 2)
+	release := make(chan struct{})
+	fn := func(context.Context) error {
+		running <- struct{}{}
+		<-release
+		return nil
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := s.Add(JobConfiguration{Name: name, Func: fn, Tick: time.Hour}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.RunOnce(context.Background()) }()
+	<-running
+	// второй запуск ждёт слот, пока первый держит единственный
+	deadline := time.Now().Add(time.Second)
+	for s.RateStats().Waits == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no rate wait recorded while the limiter is saturated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if st := s.RateStats(); st.InFlight != 1 || st.Capacity != 1 {
+		t.Fatalf("stats %+v, want 1 in flight of 1", st)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	st := s.RateStats()
+	if st.Waits != 1 || st.WaitTime < 20*time.Millisecond || st.InFlight != 0 {
+		t.Fatalf("stats %+v, want one wait of at least 20ms and nothing in flight", st)
+	}
+}
+
+func TestRunningReflectsExecutionAndCancelRunningInterruptsIt(t *testing.T) {
+	s := NewJobScheduler(2)
+	started := make(chan struct{})
+	runErr := make(chan error, 1)
+	fn := func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		runErr <- ctx.Err()
+		return nil
+	}
+	if err := s.Add(JobConfiguration{Name: "slow", Func: fn, Tick: time.Hour, GracefulStopTimeout: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(JobConfiguration{Name: "idle", Func: func(context.Context) error { return nil }, Tick: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Running(); len(got) != 0 {
+		t.Fatalf("running %v before any execution", got)
+	}
+	if s.CancelRunning("slow") {
+		t.Fatal("CancelRunning reported success for an idle job")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.RunOnce(context.Background()) }()
+	<-started
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		got := s.Running()
+		if len(got) == 1 && got[0].Name == "slow" && !got[0].Since.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("running %v, want only slow", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s.CancelRunning("missing") {
+		t.Fatal("CancelRunning reported success for an unknown job")
+	}
+	if !s.CancelRunning("slow") {
+		t.Fatal("CancelRunning did not find the running execution")
+	}
+
+	select {
+	case err := <-runErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("run context error %v, want Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("CancelRunning did not interrupt the run")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Running(); len(got) != 0 {
+		t.Fatalf("running %v after the execution finished", got)
+	}
+}
+
+func TestStartAndStopLogLifecycle(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "noop", Func: func(context.Context) error { return nil }, Tick: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Start(context.Background())()
+	s.Stop()()
+
+	for _, state := range []string{"started", "stopping", "stopped"} {
+		entries := logs.WithMessage("scheduler " + state)
+		if len(entries) != 1 || entries[0]["component"] != "scheduler" || entries[0]["state"] != state {
+			t.Fatalf("state %s: entries %v", state, entries)
+		}
+	}
+	if started := logs.WithMessage("scheduler started"); started[0]["jobs"] != float64(1) {
+		t.Fatalf("jobs %v, want 1", started[0]["jobs"])
+	}
+}
+
+func TestStopForceReturnsPromptlyWithLongGracefulJob(t *testing.T) {
+	s := NewJobScheduler(1)
+	started := make(chan struct{}, 1)
+	runErr := make(chan error, 1)
+	fn := func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		select {
+		case runErr <- ctx.Err():
+		default:
+		}
+		return nil
+	}
+	cfg := JobConfiguration{
+		Name:                "stuck",
+		Func:                fn,
+		Tick:                10 * time.Millisecond,
+		StopMode:            StopGraceful,
+		ExecTimeout:         time.Hour,
+		GracefulStopTimeout: time.Hour,
+	}
+	if err := s.Add(cfg); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	<-started
+
+	start := time.Now()
+	err := s.StopForce(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("StopForce took %s, budget is 50ms", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Fatalf("err %v, want the unfinished job reported", err)
+	}
+	if st := s.RateStats(); st.InFlight != 0 || st.Capacity != 1 {
+		t.Fatalf("stats %+v, want the rate slot reclaimed", st)
+	}
+
+	select {
+	case err := <-runErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("run ctx error %v, want Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StopForce did not cancel the running execution")
+	}
+}
+
+func TestStopForceWithoutStuckJobs(t *testing.T) {
+	s := NewJobScheduler(1)
+	if err := s.Add(JobConfiguration{Name: "noop", Func: func(context.Context) error { return nil }, Tick: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	if err := s.StopForce(time.Second); err != nil {
+		t.Fatalf("StopForce of idle jobs: %v", err)
+	}
+}
+
+func TestUpdateTickTakesEffectAtRuntime(t *testing.T) {
+	s := NewJobScheduler(1)
+	var mu sync.Mutex
+	var runs []time.Time
+	if err := s.Add(JobConfiguration{Name: "poll", Tick: time.Hour, Func: func(context.Context) error {
+		mu.Lock()
+		runs = append(runs, time.Now())
+		mu.Unlock()
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(runs)
+	}
+	s.Start(context.Background())()
+	defer s.Stop()()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := count(); n != 0 {
+		t.Fatalf("%d runs with an hourly tick", n)
+	}
+
+	if err := s.UpdateTick("poll", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for count() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d runs after switching to a 20ms tick", count())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	gap := runs[2].Sub(runs[1])
+	mu.Unlock()
+	if gap < 10*time.Millisecond || gap > 200*time.Millisecond {
+		t.Fatalf("interval between runs %s, want about 20ms", gap)
+	}
+
+	if err := s.UpdateTick("poll", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// запуск, уже выбравший старый тик, может успеть пройти
+	time.Sleep(30 * time.Millisecond)
+	before := count()
+	time.Sleep(100 * time.Millisecond)
+	if n := count(); n != before {
+		t.Fatalf("runs grew from %d to %d after backing off to an hourly tick", before, n)
+	}
+}
+
+func TestUpdateTickKeepsCurrentRun(t *testing.T) {
+	s := NewJobScheduler(1)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+	if err := s.Add(JobConfiguration{Name: "slow", Tick: 5 * time.Millisecond, Func: func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+			return nil
+		}
+		<-release
+		finished <- ctx.Err()
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())()
+	defer s.Stop()()
+
+	<-started
+	if err := s.UpdateTick("slow", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-finished; err != nil {
+		t.Fatalf("running execution interrupted by UpdateTick: %v", err)
+	}
+}
+
+func TestUpdateTickValidation(t *testing.T) {
+	s := NewJobScheduler(1)
+	noop := func(context.Context) error { return nil }
+	if err := s.Add(JobConfiguration{Name: "tick", Func: noop, Tick: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(JobConfiguration{Name: "calendar", Func: noop, Schedule: "0 0 * * * *"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"zero tick":     s.UpdateTick("tick", 0),
+		"negative tick": s.UpdateTick("tick", -time.Second),
+		"unknown job":   s.UpdateTick("missing", time.Second),
+		"calendar job":  s.UpdateTick("calendar", time.Second),
+	} {
+		if err == nil {
+			t.Errorf("%s: UpdateTick accepted", name)
+		}
+	}
+	// до Start новый интервал просто запоминается
+	if err := s.UpdateTick("tick", time.Minute); err != nil {
+		t.Fatalf("UpdateTick before Start: %v", err)
+	}
+}
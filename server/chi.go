@@ -1,7 +1,6 @@
 package server
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -13,7 +12,6 @@ import (
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
 	"github.com/PavelAgarkov/service-pkg/utils"
 	"github.com/go-chi/chi/v5"
-	"github.com/rs/xid"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +19,18 @@ type HTTPServerChi struct {
 	port   string
 	Router *chi.Mux
 	logger *zap.Logger
+	// InFlight число обрабатываемых запросов, при остановке по нему пишется прогресс дренажа
+	InFlight *InFlightCounter
+	// BaseContext базовый контекст запросов (http.Server.BaseContext): например app ctx или
+	// application.WithShutdownState, чтобы хэндлеры видели значения уровня приложения. nil — context.Background().
+	BaseContext func(net.Listener) context.Context
+	// ConnState колбэк смены состояния соединений (http.Server.ConnState), например ConnStats.Track. nil — без него.
+	ConnState func(net.Conn, http.ConnState)
+	// TLS HTTPS вместо HTTP: готовый tls.Config (mTLS, свои cipher suites) и/или пути к сертификату и ключу.
+	// Пустой TLSConfig с пустыми путями — обычный HTTP.
+	TLS TLSConfig
+
+	onShutdown []func()
 }
 
 // CreateHTTPChiServer создаёт и запускает HTTP-сервер на chi.
@@ -42,8 +52,9 @@ func CreateHTTPChiServer(
 
 func newHTTPServer(port string) *HTTPServerChi {
 	return &HTTPServerChi{
-		port:   port,
-		Router: chi.NewRouter(),
+		port:     port,
+		Router:   chi.NewRouter(),
+		InFlight: NewInFlightCounter(),
 	}
 }
 
@@ -53,20 +64,22 @@ func (s *HTTPServerChi) apply(cfg func(*HTTPServerChi)) {
 
 func (s *HTTPServerChi) run(balancer http.Handler) func() {
 	srv := &http.Server{
-		Addr:    s.port,
-		Handler: ifNil(balancer, s.Router),
+		Addr:        s.port,
+		Handler:     s.InFlight.Middleware(ifNil(balancer, s.Router)),
+		BaseContext: s.BaseContext,
+		ConnState:   s.ConnState,
+		TLSConfig:   s.TLS.Config,
+	}
+
+	for _, f := range s.onShutdown {
+		srv.RegisterOnShutdown(f)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	utils.GoRecover(ctx, func(ctx context.Context) {
 		defer cancel()
-		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
-			Msg:       fmt.Sprintf("HTTP server listening on %s", s.port),
-			Component: "HTTPServer",
-			Method:    "run",
-			Args:      s.port,
-		})
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.LogLifecycle(ctx, "HTTPServer", logger.LifecycleStarted, logger.WithField("addr", s.port))
+		if err := s.TLS.listenAndServe(srv); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic(fmt.Sprintf("server stopped: %s", err))
 		}
 	})
@@ -76,16 +89,15 @@ func (s *HTTPServerChi) run(balancer http.Handler) func() {
 
 func (s *HTTPServerChi) shutdown(srv *http.Server) func() {
 	return func() {
-		logger.WriteInfoLog(context.Background(), &logger_wrapper.LogEntry{
-			Msg:       "Shutting down HTTP server...",
-			Component: "HTTPServer",
-			Method:    "shutdown",
-			Args:      s.port,
-		})
+		logger.LogLifecycle(context.Background(), "HTTPServer", logger.LifecycleStopping, logger.WithField("addr", s.port))
+		defer logger.LogLifecycle(context.Background(), "HTTPServer", logger.LifecycleStopped, logger.WithField("addr", s.port))
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
+		stopProgress := logDrainProgress(ctx, s.InFlight, s.port)
+		defer stopProgress()
+
 		if err := srv.Shutdown(ctx); err != nil {
 			logger.WriteErrorLog(context.Background(), &logger_wrapper.LogEntry{
 				Msg:       "HTTP shutdown failed",
@@ -98,6 +110,13 @@ func (s *HTTPServerChi) shutdown(srv *http.Server) func() {
 	}
 }
 
+// RegisterOnShutdown добавляет колбэк, который http.Server вызовет в начале Shutdown (http.Server.RegisterOnShutdown).
+// Shutdown не ждёт и не закрывает hijacked-соединения (websocket и т.п.) — закрывать их нужно здесь.
+// Вызывать в routes до запуска сервера.
+func (s *HTTPServerChi) RegisterOnShutdown(f func()) {
+	s.onShutdown = append(s.onShutdown, f)
+}
+
 func ifNil(balancer, router http.Handler) http.Handler {
 	if balancer != nil {
 		return balancer
@@ -105,82 +124,17 @@ func ifNil(balancer, router http.Handler) http.Handler {
 	return router
 }
 
-type contextChiKey string
-
-const correlationChiIDCtxKey contextChiKey = "correlation_id"
-
+// LoggerChiContextMiddleware то же, что LoggerContextMiddleware.
 func LoggerChiContextMiddleware() func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := r.Context()
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
+	return loggerContextMiddleware
 }
 
 // RecoverChiMiddleware ловит panic внутри хэндлеров.
 func RecoverChiMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func(c context.Context) {
-			if rec := recover(); rec != nil {
-				logger.WriteErrorLog(c, &logger_wrapper.LogEntry{
-					Msg:       "panic caught in HTTP request",
-					Error:     fmt.Errorf("%v", rec),
-					Component: "HTTPServer",
-					Method:    "RecoverMiddleware",
-				})
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		}(r.Context())
-		next.ServeHTTP(w, r)
-	})
+	return recoverMiddleware(next)
 }
 
-// LoggingChiMiddleware логирует запрос, добавляет X-Correlation-ID.
+// LoggingChiMiddleware логирует запрос, добавляет X-Correlation-ID (входящий из заголовка или новый).
 func LoggingChiMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		corrID := xid.New().String()
-		ctx := context.WithValue(r.Context(), correlationChiIDCtxKey, corrID)
-
-		w.Header().Set("X-Correlation-ID", corrID)
-
-		lrw := newLoggingChiResponseWriter(w)
-
-		start := time.Now()
-		next.ServeHTTP(lrw, r.WithContext(ctx))
-
-		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
-			Msg:       fmt.Sprintf("%s %s completed", r.Method, r.URL.Path),
-			Component: "HTTPServer",
-			Method:    "LoggingMiddleware",
-			Args: fmt.Sprintf("status=%d duration=%s ua=%s",
-				lrw.statusCode, time.Since(start), r.UserAgent()),
-		})
-	})
-}
-
-type loggingChiResponseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func newLoggingChiResponseWriter(w http.ResponseWriter) *loggingChiResponseWriter {
-	return &loggingChiResponseWriter{w, http.StatusOK}
-}
-
-func (lrw *loggingChiResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
-	lrw.ResponseWriter.WriteHeader(code)
-}
-
-func (lrw *loggingChiResponseWriter) Write(b []byte) (int, error) {
-	return lrw.ResponseWriter.Write(b)
-}
-
-func (lrw *loggingChiResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj, ok := lrw.ResponseWriter.(http.Hijacker)
-	if !ok {
-		return nil, nil, errors.New("ResponseWriter does not implement http.Hijacker")
-	}
-	return hj.Hijack()
+	return loggingMiddleware(next)
 }
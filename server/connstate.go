@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ConnStats текущее число соединений HTTP-сервера в состояниях new/active/idle
+// и сколько соединений всего закрыто и отдано через hijack (websocket и т.п.). Подключается через поле ConnState серверов: s.ConnState = stats.Track.
+// При graceful shutdown idle-соединения закрываются сразу, active — по завершении запросов, и это видно в счётчиках.
+type ConnStats struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]http.ConnState
+	counts map[http.ConnState]int64
+	closed int64
+}
+
+type ConnStatsSnapshot struct {
+	New, Active, Idle int64
+	Closed, Hijacked  int64
+}
+
+func NewConnStats() *ConnStats {
+	return &ConnStats{
+		conns:  make(map[net.Conn]http.ConnState),
+		counts: make(map[http.ConnState]int64),
+	}
+}
+
+// Track колбэк для http.Server.ConnState.
+func (s *ConnStats) Track(conn net.Conn, state http.ConnState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if prev, ok := s.conns[conn]; ok {
+		s.counts[prev]--
+	}
+	switch state {
+	case http.StateClosed:
+		delete(s.conns, conn)
+		s.closed++
+	case http.StateHijacked:
+		// после hijack сервер больше не сообщает о соединении, поэтому в карте его не держим
+		delete(s.conns, conn)
+		s.counts[state]++
+	default:
+		s.conns[conn] = state
+		s.counts[state]++
+	}
+}
+
+func (s *ConnStats) Snapshot() ConnStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ConnStatsSnapshot{
+		New:      s.counts[http.StateNew],
+		Active:   s.counts[http.StateActive],
+		Idle:     s.counts[http.StateIdle],
+		Hijacked: s.counts[http.StateHijacked],
+		Closed:   s.closed,
+	}
+}
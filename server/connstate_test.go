@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+func TestConnStatsTrackTransitions(t *testing.T) {
+	stats := NewConnStats()
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	steps := []struct {
+		conn  net.Conn
+		state http.ConnState
+		want  ConnStatsSnapshot
+	}{
+		{a, http.StateNew, ConnStatsSnapshot{New: 1}},
+		{b, http.StateNew, ConnStatsSnapshot{New: 2}},
+		{a, http.StateActive, ConnStatsSnapshot{New: 1, Active: 1}},
+		{a, http.StateIdle, ConnStatsSnapshot{New: 1, Idle: 1}},
+		{b, http.StateActive, ConnStatsSnapshot{Active: 1, Idle: 1}},
+		{b, http.StateHijacked, ConnStatsSnapshot{Idle: 1, Hijacked: 1}},
+		{a, http.StateClosed, ConnStatsSnapshot{Hijacked: 1, Closed: 1}},
+	}
+	for i, s := range steps {
+		stats.Track(s.conn, s.state)
+		if got := stats.Snapshot(); got != s.want {
+			t.Fatalf("step %d (%s): %+v, want %+v", i, s.state, got, s.want)
+		}
+	}
+}
+
+func waitConnStats(t *testing.T, stats *ConnStats, want ConnStatsSnapshot) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for stats.Snapshot() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("stats %+v, want %+v", stats.Snapshot(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnStatsObservesServerConnections(t *testing.T) {
+	base := utils.ActiveGoRecoverCount()
+	stats := NewConnStats()
+	inHandler := make(chan ConnStatsSnapshot, 1)
+	addr := freeAddr(t)
+	shutdown := CreateHTTPChiServer(func(s *HTTPServerChi) {
+		s.ConnState = stats.Track
+		s.Router.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+			select {
+			case inHandler <- stats.Snapshot():
+			default:
+			}
+			_, _ = io.WriteString(w, "ok")
+		})
+	}, addr)
+
+	getWhenUp(t, "http://"+addr+"/")
+	if got := <-inHandler; got.Active != 1 {
+		t.Fatalf("stats inside the handler %+v, want one active connection", got)
+	}
+	// keep-alive соединение клиента после ответа становится idle
+	waitConnStats(t, stats, ConnStatsSnapshot{Idle: 1})
+
+	// graceful shutdown закрывает idle-соединение
+	stopServer(t, shutdown, base)
+	waitConnStats(t, stats, ConnStatsSnapshot{Closed: 1})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/rs/xid"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	CorrelationIDHeader = "X-Correlation-ID"
+	// CorrelationIDMetadata ключ gRPC-метаданных (ключи метаданных в нижнем регистре)
+	CorrelationIDMetadata = "x-correlation-id"
+
+	// maxCorrelationIDLen входящий ID длиннее считается мусором и заменяется новым
+	maxCorrelationIDLen = 128
+)
+
+type correlationIDKey struct{}
+
+// WithCorrelationID кладёт correlation ID в контекст: его видят CorrelationIDFromContext
+// и логгер (поле correlation_id в каждой записи с этим контекстом).
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, correlationIDKey{}, id)
+	return logger.WithContextField(ctx, "correlation_id", id)
+}
+
+// CorrelationIDFromContext correlation ID текущего запроса, выставленный Logging*Middleware или Logging*Interceptor.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// requestCorrelationID берёт ID из входящего X-Correlation-ID, чтобы он сквозным проходил через сервисы.
+// Пустой или подозрительный заголовок (длинный, с управляющими символами) заменяется новым xid.
+func requestCorrelationID(r *http.Request) string {
+	if id := r.Header.Get(CorrelationIDHeader); validCorrelationID(id) {
+		return id
+	}
+	return xid.New().String()
+}
+
+// rpcCorrelationID то же для gRPC: из входящих метаданных x-correlation-id или новый xid.
+func rpcCorrelationID(ctx context.Context) string {
+	if values := metadata.ValueFromIncomingContext(ctx, CorrelationIDMetadata); len(values) > 0 && validCorrelationID(values[0]) {
+		return values[0]
+	}
+	return xid.New().String()
+}
+
+func validCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// serveWithCorrelation прогоняет запрос через LoggingMiddleware и возвращает ответ
+// и ID, который увидел хэндлер через CorrelationIDFromContext
+func serveWithCorrelation(t *testing.T, header string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	var seen string
+	handler := loggingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := CorrelationIDFromContext(r.Context())
+		if !ok {
+			t.Error("no correlation ID in the handler context")
+		}
+		seen = id
+		logger.WriteInfoLog(r.Context(), &logger_wrapper.LogEntry{Msg: "handler work"})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	if header != "" {
+		req.Header.Set(CorrelationIDHeader, header)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, seen
+}
+
+func TestLoggingMiddlewareHonoursInboundCorrelationID(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+
+	rec, seen := serveWithCorrelation(t, "upstream-123")
+
+	if seen != "upstream-123" {
+		t.Fatalf("handler saw %q, want the inbound ID", seen)
+	}
+	if got := rec.Header().Get(CorrelationIDHeader); got != "upstream-123" {
+		t.Fatalf("response header %q, want the inbound ID", got)
+	}
+	for _, msg := range []string{"handler work", "GET /orders completed"} {
+		entries := logs.WithMessage(msg)
+		if len(entries) != 1 || entries[0]["correlation_id"] != "upstream-123" {
+			t.Fatalf("%q entries %v, want correlation_id upstream-123", msg, entries)
+		}
+	}
+}
+
+func TestLoggingMiddlewareGeneratesCorrelationID(t *testing.T) {
+	for name, header := range map[string]string{
+		"missing":      "",
+		"control char": "bad\nid",
+		"too long":     strings.Repeat("x", maxCorrelationIDLen+1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			logs := logtest.Capture(t, logger.InitLogger)
+
+			rec, seen := serveWithCorrelation(t, header)
+
+			if seen == "" || seen == header {
+				t.Fatalf("handler saw %q, want a generated ID", seen)
+			}
+			if got := rec.Header().Get(CorrelationIDHeader); got != seen {
+				t.Fatalf("response header %q, handler saw %q", got, seen)
+			}
+			if entries := logs.WithMessage("handler work"); len(entries) != 1 || entries[0]["correlation_id"] != seen {
+				t.Fatalf("entries %v, want correlation_id %s", entries, seen)
+			}
+		})
+	}
+}
+
+func TestCorrelationIDFromContextWithoutID(t *testing.T) {
+	if id, ok := CorrelationIDFromContext(context.Background()); ok || id != "" {
+		t.Fatalf("got %q, %v from an empty context", id, ok)
+	}
+}
+
+// correlationHealth отдаёт в seen correlation ID из контекста Check
+type correlationHealth struct {
+	healthpb.UnimplementedHealthServer
+	seen chan string
+}
+
+func (h correlationHealth) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	id, _ := CorrelationIDFromContext(ctx)
+	h.seen <- id
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func TestLoggingUnaryInterceptorCorrelationID(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+	svc := correlationHealth{seen: make(chan string, 2)}
+	client := startBufconn(t, svc, grpc.ChainUnaryInterceptor(LoggingUnaryInterceptor()))
+
+	var trailer metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), CorrelationIDMetadata, "upstream-456")
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer)); err != nil {
+		t.Fatal(err)
+	}
+	if seen := <-svc.seen; seen != "upstream-456" {
+		t.Fatalf("handler saw %q, want the inbound ID", seen)
+	}
+	if got := trailer.Get(CorrelationIDMetadata); len(got) != 1 || got[0] != "upstream-456" {
+		t.Fatalf("trailer %v, want the inbound ID", got)
+	}
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer)); err != nil {
+		t.Fatal(err)
+	}
+	seen := <-svc.seen
+	if seen == "" || seen == "upstream-456" {
+		t.Fatalf("handler saw %q, want a generated ID", seen)
+	}
+	if got := trailer.Get(CorrelationIDMetadata); len(got) != 1 || got[0] != seen {
+		t.Fatalf("trailer %v, handler saw %q", got, seen)
+	}
+}
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -13,7 +12,6 @@ import (
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
 	"github.com/PavelAgarkov/service-pkg/utils"
 	"github.com/gorilla/mux"
-	"github.com/rs/xid"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +19,18 @@ type HTTPServer struct {
 	port   string
 	Router *mux.Router
 	logger *zap.Logger
+	// InFlight число обрабатываемых запросов, при остановке по нему пишется прогресс дренажа
+	InFlight *InFlightCounter
+	// BaseContext базовый контекст запросов (http.Server.BaseContext): например app ctx или
+	// application.WithShutdownState, чтобы хэндлеры видели значения уровня приложения. nil — context.Background().
+	BaseContext func(net.Listener) context.Context
+	// ConnState колбэк смены состояния соединений (http.Server.ConnState), например ConnStats.Track. nil — без него.
+	ConnState func(net.Conn, http.ConnState)
+	// TLS HTTPS вместо HTTP: готовый tls.Config (mTLS, свои cipher suites) и/или пути к сертификату и ключу.
+	// Пустой TLSConfig с пустыми путями — обычный HTTP.
+	TLS TLSConfig
+
+	onShutdown []func()
 }
 
 func (simple *HTTPServer) RunHTTPServer(balancer http.Handler, mwf ...mux.MiddlewareFunc) func() {
@@ -29,26 +39,31 @@ func (simple *HTTPServer) RunHTTPServer(balancer http.Handler, mwf ...mux.Middle
 	var server *http.Server
 	if balancer != nil {
 		server = &http.Server{
-			Addr:    simple.port,
-			Handler: balancer,
+			Addr:        simple.port,
+			Handler:     simple.InFlight.Middleware(balancer),
+			BaseContext: simple.BaseContext,
+			ConnState:   simple.ConnState,
+			TLSConfig:   simple.TLS.Config,
 		}
 	} else {
 		server = &http.Server{
-			Addr:    simple.port,
-			Handler: simple.Router,
+			Addr:        simple.port,
+			Handler:     simple.InFlight.Middleware(simple.Router),
+			BaseContext: simple.BaseContext,
+			ConnState:   simple.ConnState,
+			TLSConfig:   simple.TLS.Config,
 		}
 	}
 
+	for _, f := range simple.onShutdown {
+		server.RegisterOnShutdown(f)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	utils.GoRecover(ctx, func(ctx context.Context) {
 		defer cancel()
-		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
-			Msg:       fmt.Sprintf("Server is running on %s", simple.port),
-			Component: "HTTPServer",
-			Method:    "RunHTTPServer",
-			Args:      simple.port,
-		})
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.LogLifecycle(ctx, "HTTPServer", logger.LifecycleStarted, logger.WithField("addr", simple.port))
+		if err := simple.TLS.listenAndServe(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic(fmt.Sprintf("Server stopped by error: %s", err))
 		}
 		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
@@ -62,15 +77,15 @@ func (simple *HTTPServer) RunHTTPServer(balancer http.Handler, mwf ...mux.Middle
 	return simple.shutdown(server)
 }
 
+// RegisterOnShutdown то же, что HTTPServerChi.RegisterOnShutdown.
+func (simple *HTTPServer) RegisterOnShutdown(f func()) {
+	simple.onShutdown = append(simple.onShutdown, f)
+}
+
 // Shutdown gracefully shuts down the server without interrupting any active connections.
 func (simple *HTTPServer) shutdown(server *http.Server) func() {
 	return func() {
-		logger.WriteInfoLog(context.Background(), &logger_wrapper.LogEntry{
-			Msg:       "Shutting down the server...",
-			Component: "HTTPServer",
-			Method:    "shutdown",
-			Args:      simple.port,
-		})
+		logger.LogLifecycle(context.Background(), "HTTPServer", logger.LifecycleStopping, logger.WithField("addr", simple.port))
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		// использовать server.Shutdown(ctx) вместо server.Close() для корректного завершения запросов
@@ -78,7 +93,10 @@ func (simple *HTTPServer) shutdown(server *http.Server) func() {
 		// последние запросы за 5 секунд будут обработаны
 		// после этого сервер будет остановлен
 		// если необходимо остановить сервер сразу, то использовать server.Close()
-		if err := server.Shutdown(ctx); err != nil {
+		stopProgress := logDrainProgress(ctx, simple.InFlight, simple.port)
+		err := server.Shutdown(ctx)
+		stopProgress()
+		if err != nil {
 			logger.WriteErrorLog(context.Background(), &logger_wrapper.LogEntry{
 				Msg:       fmt.Sprintf("Server shutdown failed: %s", err),
 				Error:     err,
@@ -87,12 +105,7 @@ func (simple *HTTPServer) shutdown(server *http.Server) func() {
 				Args:      simple.port,
 			})
 		}
-		logger.WriteInfoLog(context.Background(), &logger_wrapper.LogEntry{
-			Msg:       fmt.Sprintf("Server has done: %s", simple.port),
-			Component: "HTTPServer",
-			Method:    "shutdown",
-			Args:      simple.port,
-		})
+		logger.LogLifecycle(context.Background(), "HTTPServer", logger.LifecycleStopped, logger.WithField("addr", simple.port))
 	}
 }
 
@@ -109,8 +122,9 @@ func CreateHttpServer(router func(simple *HTTPServer), port string, mwf ...mux.M
 
 func newSimpleHTTPServer(port string) *HTTPServer {
 	return &HTTPServer{
-		Router: mux.NewRouter(),
-		port:   port,
+		Router:   mux.NewRouter(),
+		port:     port,
+		InFlight: NewInFlightCounter(),
 	}
 }
 
@@ -120,96 +134,13 @@ func (simple *HTTPServer) ToConfigureHandlers(configure func(simple *HTTPServer)
 }
 
 func LoggerContextMiddleware() mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Добавляем логгер в контекст запроса
-			ctx := r.Context()
-			r = r.WithContext(ctx)
-
-			// Создаём новый ResponseWriter для логирования ответа (опционально)
-			lrw := newLoggingResponseWriter(w)
-
-			// Передаём обработку следующему обработчику в цепочке
-			next.ServeHTTP(lrw, r)
-		})
-	}
+	return loggerContextMiddleware
 }
 
 func RecoverMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rr := r.WithContext(r.Context())
-		defer func() {
-			if r := recover(); r != nil {
-				logger.WriteErrorLog(rr.Context(), &logger_wrapper.LogEntry{
-					Msg:       "Паника произошла в http запросе приложении",
-					Error:     fmt.Errorf("%v", r),
-					Component: "HTTPServer",
-					Method:    "RecoverMiddleware",
-				})
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		}()
-		next.ServeHTTP(w, r)
-	})
+	return recoverMiddleware(next)
 }
 
-type contextKey string
-
-const (
-	correlationIDCtxKey contextKey = "correlation_id"
-)
-
 func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		correlationID := xid.New().String()
-
-		ctx := context.WithValue(
-			r.Context(),
-			correlationIDCtxKey,
-			correlationID,
-		)
-
-		r = r.WithContext(ctx)
-		w.Header().Add("X-Correlation-ID", correlationID)
-
-		lrw := newLoggingResponseWriter(w)
-
-		defer func(start time.Time) {
-			logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
-				Msg:       fmt.Sprintf("%s request to %s completed", r.Method, r.RequestURI),
-				Component: "HTTPServer",
-				Method:    "LoggingMiddleware",
-				Args: fmt.Sprintf("method: %s, url: %s, user_agent: %s, status_code: %d, elapsed_ms: %s",
-					r.Method, r.RequestURI, r.UserAgent(), lrw.statusCode, time.Since(start)),
-			})
-		}(time.Now())
-		next.ServeHTTP(lrw, r)
-	})
-}
-
-func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj, ok := lrw.ResponseWriter.(http.Hijacker)
-	if !ok {
-		return nil, nil, errors.New("the ResponseWriter does not implement http.Hijacker")
-	}
-	return hj.Hijack()
-}
-
-func (lrw *loggingResponseWriter) Write(data []byte) (int, error) {
-	return lrw.ResponseWriter.Write(data)
-}
-
-type loggingResponseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-// Override метода WriteHeader для логирования статуса
-func (lrw *loggingResponseWriter) WriteHeader(code int) {
-	lrw.statusCode = code
-	lrw.ResponseWriter.WriteHeader(code)
-}
-
-func newLoggingResponseWriter(w http.ResponseWriter) *loggingResponseWriter {
-	return &loggingResponseWriter{w, http.StatusOK}
+	return loggingMiddleware(next)
 }
@@ -9,9 +9,15 @@ import (
 
 	"github.com/PavelAgarkov/service-pkg/logger"
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/readiness_barrier"
 	"github.com/PavelAgarkov/service-pkg/utils"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -21,11 +27,63 @@ type Configs struct {
 	Port       string
 	Network    string
 	Reflection bool
+
+	// Keepalive сервера (grpc.KeepaliveParams), нулевое значение — умолчание gRPC.
+	// За L4-балансировщиком, который рвёт простаивающие соединения, Time должен быть меньше его idle-таймаута.
+	// MaxConnectionIdle закрыть соединение без RPC дольше этого времени.
+	MaxConnectionIdle time.Duration
+	// MaxConnectionAge максимальный возраст соединения: клиенты периодически переподключаются и равномернее
+	// распределяются по инстансам. Начатые RPC дорабатывают (grace по умолчанию gRPC — бесконечный).
+	MaxConnectionAge time.Duration
+	// Time через сколько простоя сервер пингует клиента, Timeout — сколько ждёт ответа на пинг.
+	Time    time.Duration
+	Timeout time.Duration
+
+	// Enforcement policy (grpc.KeepaliveEnforcementPolicy): клиента, пингующего чаще MinTime,
+	// сервер отключает с GOAWAY too_many_pings. Нулевые MinTime и PermitWithoutStream — умолчание gRPC.
+	MinTime time.Duration
+	// PermitWithoutStream разрешить клиентские пинги без активных RPC.
+	PermitWithoutStream bool
+
+	// Health стандартный grpc.health.v1 для k8s-проб: сервер регистрируется в Start (и в CreateGRPCHTTPServer),
+	// на остановке переходит в NOT_SERVING.
+	// Создаётся вызывающим (health.NewServer()), он же через него выставляет статусы отдельных сервисов. nil — без health.
+	Health *health.Server
+	// HealthBarrier барьер готовности, за которым следует общий статус (сервис ""): ready — SERVING, иначе NOT_SERVING.
+	// Барьер опрашивается раз в healthSyncInterval. nil — общий статус не трогаем (у health.NewServer он SERVING).
+	HealthBarrier readiness_barrier.ReadinessBarrierInterface
 }
 
+// keepaliveOptions переводит keepalive-настройки Configs в опции сервера. Они ставятся перед опциями вызывающего,
+// поэтому явные grpc.KeepaliveParams/KeepaliveEnforcementPolicy в serverOptions их перекрывают.
+// С EnforceMaxSendSize не пересекаются: keepalive живёт на уровне соединения, лимит ответа — на уровне RPC.
+// Но MaxConnectionAge не спасает от огромного ответа: RPC, начатый до истечения возраста, доработает и отправит его.
+func (c Configs) keepaliveOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	params := keepalive.ServerParameters{
+		MaxConnectionIdle: c.MaxConnectionIdle,
+		MaxConnectionAge:  c.MaxConnectionAge,
+		Time:              c.Time,
+		Timeout:           c.Timeout,
+	}
+	if params != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(params))
+	}
+	if c.MinTime > 0 || c.PermitWithoutStream {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             c.MinTime,
+			PermitWithoutStream: c.PermitWithoutStream,
+		}))
+	}
+	return opts
+}
+
+const healthSyncInterval = time.Second
+
 type GRPCServer struct {
-	configs Configs
-	server  *grpc.Server
+	configs    Configs
+	server     *grpc.Server
+	stopHealth func()
 }
 
 func newGRPCServer(configs Configs) *GRPCServer {
@@ -35,11 +93,12 @@ func newGRPCServer(configs Configs) *GRPCServer {
 }
 
 func (s *GRPCServer) Start(ctx context.Context, registerServices func(*grpc.Server), serverOptions ...grpc.ServerOption) func() {
-	s.server = grpc.NewServer(serverOptions...)
+	s.server = grpc.NewServer(append(s.configs.keepaliveOptions(), serverOptions...)...)
 	registerServices(s.server)
 	if s.configs.Reflection {
 		reflection.Register(s.server)
 	}
+	s.stopHealth = s.configs.registerHealth(ctx, s.server)
 
 	listener, err := net.Listen(s.configs.Network, s.configs.Port)
 	if err != nil {
@@ -53,12 +112,7 @@ func (s *GRPCServer) Start(ctx context.Context, registerServices func(*grpc.Serv
 	}
 
 	utils.GoRecover(ctx, func(ctx context.Context) {
-		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
-			Msg:       fmt.Sprintf("gRPC server is started on %s", s.configs.Port),
-			Args:      s.configs,
-			Component: "GRPCServer",
-			Method:    "Start",
-		})
+		logger.LogLifecycle(ctx, "GRPCServer", logger.LifecycleStarted, logger.WithField("addr", s.configs.Port))
 		if err = s.server.Serve(listener); err != nil {
 			panic(fmt.Sprintf("Server gRPC stopped by error: %v", err))
 		}
@@ -69,11 +123,11 @@ func (s *GRPCServer) Start(ctx context.Context, registerServices func(*grpc.Serv
 
 func (s *GRPCServer) shutdown() {
 	logCtx := context.Background()
-	logger.WriteInfoLog(logCtx, &logger_wrapper.LogEntry{
-		Msg:       "Shutting down gRPC server",
-		Component: "GRPCServer",
-		Method:    "shutdown",
-	})
+	logger.LogLifecycle(logCtx, "GRPCServer", logger.LifecycleStopping, logger.WithField("addr", s.configs.Port))
+	defer logger.LogLifecycle(logCtx, "GRPCServer", logger.LifecycleStopped, logger.WithField("addr", s.configs.Port))
+
+	// пробы видят NOT_SERVING ещё во время GracefulStop
+	s.stopHealth()
 
 	timeoutCtx, cancel := context.WithTimeout(logCtx, 5*time.Second)
 	defer cancel()
@@ -87,11 +141,6 @@ func (s *GRPCServer) shutdown() {
 
 	select {
 	case <-done:
-		logger.WriteInfoLog(logCtx, &logger_wrapper.LogEntry{
-			Msg:       "gRPC server has gracefully stopped.",
-			Component: "GRPCServer",
-			Method:    "shutdown",
-		})
 	case <-timeoutCtx.Done():
 		logger.WriteWarnLog(logCtx, &logger_wrapper.LogEntry{
 			Msg:       "Graceful shutdown timed out, forcing stop.",
@@ -102,6 +151,54 @@ func (s *GRPCServer) shutdown() {
 	}
 }
 
+// registerHealth регистрирует Health на srv и связывает его общий статус с HealthBarrier.
+// Возвращает остановку: синхронизация с барьером прекращается, все статусы переходят в NOT_SERVING.
+func (c Configs) registerHealth(ctx context.Context, srv *grpc.Server) func() {
+	if c.Health == nil {
+		return func() {}
+	}
+	healthpb.RegisterHealthServer(srv, c.Health)
+	stopSync := func() {}
+	if c.HealthBarrier != nil {
+		stopSync = syncHealth(ctx, c.Health, c.HealthBarrier)
+	}
+	return func() {
+		stopSync()
+		c.Health.Shutdown()
+	}
+}
+
+// syncHealth переносит состояние барьера в общий статус health-сервера: сразу и затем при каждом изменении.
+func syncHealth(ctx context.Context, hs *health.Server, barrier readiness_barrier.ReadinessBarrierInterface) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	set := func(ready bool) {
+		st := healthpb.HealthCheckResponse_NOT_SERVING
+		if ready {
+			st = healthpb.HealthCheckResponse_SERVING
+		}
+		hs.SetServingStatus("", st)
+	}
+	ready := barrier.IsReady()
+	set(ready)
+
+	utils.GoRecover(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(healthSyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if now := barrier.IsReady(); now != ready {
+					ready = now
+					set(ready)
+				}
+			}
+		}
+	})
+	return cancel
+}
+
 func CreateGRPCServer(ctx context.Context, registerServices func(*grpc.Server), configs Configs, serverOptions ...grpc.ServerOption) func() {
 	grpcServer := newGRPCServer(configs)
 	shutdownFunc := grpcServer.Start(ctx, registerServices, serverOptions...)
@@ -109,21 +206,48 @@ func CreateGRPCServer(ctx context.Context, registerServices func(*grpc.Server),
 }
 
 func PanicHandler(ctx context.Context, p interface{}) error {
-	ts := grpc.ServerTransportStreamFromContext(ctx)
-	fullMethod := ts.Method()
+	fullMethod := "unknown"
+	if ts := grpc.ServerTransportStreamFromContext(ctx); ts != nil {
+		fullMethod = ts.Method()
+	}
+	return handlePanic(ctx, fullMethod, p)
+}
+
+func handlePanic(ctx context.Context, fullMethod string, p interface{}) error {
 	stack := string(debug.Stack())
+	remoteAddr, userAgent := peerInfo(ctx)
 
 	logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
 		Msg:       "panic in gRPC handler",
 		Component: "GRPCServer",
 		Method:    fullMethod,
 		Error:     fmt.Errorf("%v", p),
-		Args:      stack,
+		Fields: map[string]any{
+			"panic_value": fmt.Sprintf("%v", p),
+			"stack":       stack,
+			"peer":        remoteAddr,
+			"user_agent":  userAgent,
+		},
 	})
 
 	return status.Errorf(codes.Internal, "internal server error (%s)", fullMethod)
 }
 
+// peerInfo достаёт адрес клиента и user-agent из контекста запроса.
+// Если peer или metadata нет (например, в in-process вызовах), возвращает "unknown".
+func peerInfo(ctx context.Context) (remoteAddr, userAgent string) {
+	remoteAddr, userAgent = "unknown", "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			userAgent = ua[0]
+		}
+	}
+	return remoteAddr, userAgent
+}
+
 // EnforceMaxSendSize это костыль, который позволяет ограничить размер ответа сервера, чтобы сервер не протекал по памяти.
 // если его убрать, то когда ответ превышает лимит, то сервер начинает течь по памяти, и в итоге падает. Днище, но нечего поделать.
 // max передавать желательно меньше чем сервер может вернуть ответом. Я обычно передают 0.9*out_grpc_body_size
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// panicHealth health-сервис, который паникует в Check
+type panicHealth struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (panicHealth) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	panic("boom")
+}
+
+func (panicHealth) Watch(*healthpb.HealthCheckRequest, healthpb.Health_WatchServer) error {
+	panic("boom")
+}
+
+// streamHealth Watch отправляет один SERVING и, если wait, держит стрим до отмены контекста
+type streamHealth struct {
+	healthpb.UnimplementedHealthServer
+	wait bool
+}
+
+func (h streamHealth) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+		return err
+	}
+	if !h.wait {
+		return nil
+	}
+	<-stream.Context().Done()
+	return status.FromContextError(stream.Context().Err()).Err()
+}
+
+// detailsHealth Check отвечает ошибкой с details
+type detailsHealth struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (detailsHealth) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := status.New(codes.FailedPrecondition, "dependency is down").
+		WithDetails(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING})
+	if err != nil {
+		return nil, err
+	}
+	return nil, st.Err()
+}
+
+// startBufconn поднимает gRPC-сервер на bufconn с переданным health-сервисом и возвращает клиента к нему
+func startBufconn(t *testing.T, svc healthpb.HealthServer, opts ...grpc.ServerOption) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(srv, svc)
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = srv.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUserAgent("audit-client"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		srv.GracefulStop()
+		<-served
+	})
+	return healthpb.NewHealthClient(conn)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/readiness_barrier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakeBarrier барьер, состояние которого тест переключает напрямую
+type fakeBarrier struct {
+	readiness_barrier.ReadinessBarrierInterface
+	ready atomic.Bool
+}
+
+func (b *fakeBarrier) IsReady() bool { return b.ready.Load() }
+
+// waitHealth ждёт, пока общий статус сервера не станет want
+func waitHealth(t *testing.T, client healthpb.HealthClient, want healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	var last healthpb.HealthCheckResponse_ServingStatus
+	deadline := time.Now().Add(3 * healthSyncInterval)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+		cancel()
+		if err == nil {
+			if last = resp.GetStatus(); last == want {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("health status %s, want %s", last, want)
+}
+
+func TestHealthFollowsReadinessBarrier(t *testing.T) {
+	addr := freeAddr(t)
+	barrier := &fakeBarrier{}
+	hs := health.NewServer()
+	hs.SetServingStatus("orders.v1.Orders", healthpb.HealthCheckResponse_SERVING)
+
+	shutdown := CreateGRPCServer(context.Background(), func(*grpc.Server) {},
+		Configs{Port: addr, Network: "tcp", Health: hs, HealthBarrier: barrier},
+	)
+	stopped := false
+	defer func() {
+		if !stopped {
+			shutdown()
+		}
+	}()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := healthpb.NewHealthClient(conn)
+
+	// барьер ещё не готов: общий статус сразу NOT_SERVING, а не SERVING по умолчанию health.NewServer
+	waitHealth(t, client, healthpb.HealthCheckResponse_NOT_SERVING)
+	barrier.ready.Store(true)
+	waitHealth(t, client, healthpb.HealthCheckResponse_SERVING)
+	barrier.ready.Store(false)
+	waitHealth(t, client, healthpb.HealthCheckResponse_NOT_SERVING)
+	barrier.ready.Store(true)
+	waitHealth(t, client, healthpb.HealthCheckResponse_SERVING)
+
+	// статусы отдельных сервисов вызывающий ведёт сам, барьер их не трогает
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "orders.v1.Orders"})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("per-service status %v, err %v", resp.GetStatus(), err)
+	}
+
+	shutdown()
+	stopped = true
+	resp, err = hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after shutdown %v, err %v", resp.GetStatus(), err)
+	}
+}
+
+func TestSharedPortHealthFollowsReadinessBarrier(t *testing.T) {
+	addr := freeAddr(t)
+	barrier := &fakeBarrier{}
+	hs := health.NewServer()
+
+	shutdown := CreateGRPCHTTPServer(context.Background(), func(*grpc.Server) {}, func(*HTTPServerChi) {},
+		Configs{Port: addr, Network: "tcp", Health: hs, HealthBarrier: barrier},
+	)
+	stopped := false
+	defer func() {
+		if !stopped {
+			shutdown()
+		}
+	}()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := healthpb.NewHealthClient(conn)
+
+	waitHealth(t, client, healthpb.HealthCheckResponse_NOT_SERVING)
+	barrier.ready.Store(true)
+	waitHealth(t, client, healthpb.HealthCheckResponse_SERVING)
+	barrier.ready.Store(false)
+	waitHealth(t, client, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	barrier.ready.Store(true)
+	waitHealth(t, client, healthpb.HealthCheckResponse_SERVING)
+	shutdown()
+	stopped = true
+	resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after shutdown %v, err %v", resp.GetStatus(), err)
+	}
+}
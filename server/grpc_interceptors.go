@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/readiness_barrier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// wrappedServerStream подменяет контекст стрима
+type wrappedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedServerStream) Context() context.Context {
+	return w.ctx
+}
+
+// UnaryPanicInterceptor ловит панику в unary-хэндлере, логирует её как PanicHandler и отвечает codes.Internal.
+func UnaryPanicInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = handlePanic(ctx, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamPanicInterceptor то же для стримов.
+func StreamPanicInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = handlePanic(ss.Context(), info.FullMethod, p)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// TimeoutStreamInterceptor ограничивает время жизни стрима. Контекст отменяется и по завершении стрима,
+// чтобы не текли таймеры.
+func TimeoutStreamInterceptor(timeout time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := context.WithTimeout(ss.Context(), timeout)
+		defer cancel()
+
+		return handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// LoggingUnaryInterceptor логирует метод, код ответа, длительность и клиента.
+// Как LoggingMiddleware на HTTP, кладёт в контекст correlation ID (входящий x-correlation-id или новый)
+// и возвращает его клиенту в трейлере.
+func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		id := rpcCorrelationID(ctx)
+		ctx = WithCorrelationID(ctx, id)
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(CorrelationIDMetadata, id))
+		resp, err := handler(ctx, req)
+		logRPC(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor то же для стримов.
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		id := rpcCorrelationID(ss.Context())
+		ctx := WithCorrelationID(ss.Context(), id)
+		ss.SetTrailer(metadata.Pairs(CorrelationIDMetadata, id))
+		err := handler(srv, &wrappedServerStream{ServerStream: ss, ctx: ctx})
+		logRPC(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logRPC(ctx context.Context, fullMethod string, start time.Time, err error) {
+	remoteAddr, userAgent := peerInfo(ctx)
+	entry := &logger_wrapper.LogEntry{
+		Msg:       fullMethod + " completed",
+		Component: "GRPCServer",
+		Method:    fullMethod,
+		Error:     err,
+		Start:     &start,
+		Fields: map[string]any{
+			"code":       status.Code(err).String(),
+			"peer":       remoteAddr,
+			"user_agent": userAgent,
+		},
+	}
+	if err != nil {
+		if st, ok := status.FromError(err); ok {
+			entry.Fields["status_message"] = st.Message()
+			if details := statusDetails(st); len(details) > 0 {
+				entry.Fields["details"] = details
+			}
+		}
+		logger.WriteWarnLog(ctx, entry)
+		return
+	}
+	logger.WriteInfoLog(ctx, entry)
+}
+
+// statusDetails переводит details статуса (errdetails.BadRequest, ErrorInfo и т.п.) в JSON-строки для лога.
+// Детали неизвестного процессу типа пишутся текстом ошибки распаковки.
+func statusDetails(st *status.Status) []string {
+	details := st.Details()
+	out := make([]string, 0, len(details))
+	for _, d := range details {
+		switch v := d.(type) {
+		case proto.Message:
+			out = append(out, protojson.Format(v))
+		default:
+			out = append(out, fmt.Sprint(v))
+		}
+	}
+	return out
+}
+
+// ReadinessUnaryInterceptor отклоняет запросы с codes.Unavailable, пока барьер не в ready.
+func ReadinessUnaryInterceptor(barrier readiness_barrier.ReadinessBarrierInterface) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !barrier.IsReady() {
+			return nil, status.Errorf(codes.Unavailable, "service is not ready (%s)", info.FullMethod)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func ReadinessStreamInterceptor(barrier readiness_barrier.ReadinessBarrierInterface) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !barrier.IsReady() {
+			return status.Errorf(codes.Unavailable, "service is not ready (%s)", info.FullMethod)
+		}
+		return handler(srv, ss)
+	}
+}
+
+// DefaultStreamChain стандартная цепочка для стримов: recovery -> logging -> readiness -> timeout.
+// barrier == nil отключает проверку готовности, timeout <= 0 — таймаут.
+func DefaultStreamChain(timeout time.Duration, barrier readiness_barrier.ReadinessBarrierInterface) grpc.ServerOption {
+	interceptors := []grpc.StreamServerInterceptor{
+		StreamPanicInterceptor(),
+		LoggingStreamInterceptor(),
+	}
+	if barrier != nil {
+		interceptors = append(interceptors, ReadinessStreamInterceptor(barrier))
+	}
+	if timeout > 0 {
+		interceptors = append(interceptors, TimeoutStreamInterceptor(timeout))
+	}
+	return grpc.ChainStreamInterceptor(interceptors...)
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/readiness_barrier"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServerStream минимальный grpc.ServerStream для вызова интерцепторов без сети
+type fakeServerStream struct {
+	ctx     context.Context
+	trailer metadata.MD
+}
+
+func (s *fakeServerStream) SetHeader(metadata.MD) error  { return nil }
+func (s *fakeServerStream) SendHeader(metadata.MD) error { return nil }
+func (s *fakeServerStream) SetTrailer(md metadata.MD)    { s.trailer = metadata.Join(s.trailer, md) }
+func (s *fakeServerStream) Context() context.Context     { return s.ctx }
+func (s *fakeServerStream) SendMsg(any) error            { return nil }
+func (s *fakeServerStream) RecvMsg(any) error            { return nil }
+
+var streamInfo = &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream", IsServerStream: true}
+
+func TestStreamPanicInterceptorReturnsInternal(t *testing.T) {
+	ss := &fakeServerStream{ctx: context.Background()}
+	err := StreamPanicInterceptor()(nil, ss, streamInfo, func(any, grpc.ServerStream) error {
+		panic("boom")
+	})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("code %s, want Internal (err %v)", status.Code(err), err)
+	}
+}
+
+func TestStreamPanicInterceptorPassesErrors(t *testing.T) {
+	ss := &fakeServerStream{ctx: context.Background()}
+	want := status.Error(codes.NotFound, "missing")
+	err := StreamPanicInterceptor()(nil, ss, streamInfo, func(any, grpc.ServerStream) error {
+		return want
+	})
+	if !errors.Is(err, want) {
+		t.Fatalf("got %v, want %v", err, want)
+	}
+}
+
+func TestTimeoutStreamInterceptorCancelsContext(t *testing.T) {
+	ss := &fakeServerStream{ctx: context.Background()}
+	err := TimeoutStreamInterceptor(20*time.Millisecond)(nil, ss, streamInfo, func(_ any, stream grpc.ServerStream) error {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(time.Second):
+			return errors.New("stream context was not cancelled by the timeout")
+		}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestTimeoutStreamInterceptorCancelsWhenStreamEnds(t *testing.T) {
+	ss := &fakeServerStream{ctx: context.Background()}
+	var streamCtx context.Context
+	err := TimeoutStreamInterceptor(time.Hour)(nil, ss, streamInfo, func(_ any, stream grpc.ServerStream) error {
+		streamCtx = stream.Context()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-streamCtx.Done():
+	default:
+		t.Fatal("stream context is still alive after the handler returned")
+	}
+}
+
+// watchErr открывает Watch и возвращает ошибку, которой закончился стрим
+func watchErr(t *testing.T, client healthpb.HealthClient) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestStreamChainRecoversPanicOverBufconn(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	client := startBufconn(t, panicHealth{}, DefaultStreamChain(0, nil))
+
+	if err := watchErr(t, client); status.Code(err) != codes.Internal {
+		t.Fatalf("code %s, want Internal (err %v)", status.Code(err), err)
+	}
+	if len(logs.WithMessage("panic in gRPC handler")) != 1 {
+		t.Fatal("stream panic was not logged")
+	}
+}
+
+func TestStreamChainRejectsWhenNotReady(t *testing.T) {
+	barrier := readiness_barrier.NewReadinessBarrier(context.Background(), readiness_barrier.ReadinessBarrierConfig{Name: "test"})
+	client := startBufconn(t, streamHealth{}, DefaultStreamChain(0, barrier))
+
+	if err := watchErr(t, client); status.Code(err) != codes.Unavailable {
+		t.Fatalf("code %s, want Unavailable (err %v)", status.Code(err), err)
+	}
+
+	barrier.Start()
+	defer barrier.Stop()
+	if err := barrier.SendSignalCtx(context.Background(), readiness_barrier.ReadySignalToggle); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); !barrier.IsReady(); {
+		if time.Now().After(deadline) {
+			t.Fatal("barrier did not become ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := watchErr(t, client); err != io.EOF {
+		t.Fatalf("ready stream ended with %v, want EOF", err)
+	}
+}
+
+func TestStreamChainLogsCompletedStream(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	client := startBufconn(t, streamHealth{}, DefaultStreamChain(0, nil))
+
+	if err := watchErr(t, client); err != io.EOF {
+		t.Fatalf("stream ended with %v, want EOF", err)
+	}
+	entries := logs.WithMessage("/grpc.health.v1.Health/Watch completed")
+	if len(entries) != 1 || entries[0]["code"] != codes.OK.String() {
+		t.Fatalf("completion entries %v, want one with code OK", entries)
+	}
+}
+
+func TestStreamChainTimeoutOverBufconn(t *testing.T) {
+	client := startBufconn(t, streamHealth{wait: true}, DefaultStreamChain(50*time.Millisecond, nil))
+
+	if err := watchErr(t, client); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("code %s, want DeadlineExceeded (err %v)", status.Code(err), err)
+	}
+}
+
+func TestLoggingInterceptorLogsStatusDetails(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	client := startBufconn(t, detailsHealth{}, grpc.ChainUnaryInterceptor(LoggingUnaryInterceptor()))
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("code %s, want FailedPrecondition", status.Code(err))
+	}
+
+	entries := logs.WithMessage("/grpc.health.v1.Health/Check completed")
+	if len(entries) != 1 {
+		t.Fatalf("%d completion entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e["code"] != codes.FailedPrecondition.String() || e["status_message"] != "dependency is down" {
+		t.Fatalf("code %v, status_message %v", e["code"], e["status_message"])
+	}
+	details, _ := e["details"].([]any)
+	if len(details) != 1 {
+		t.Fatalf("details %#v, want one entry", e["details"])
+	}
+	if d, _ := details[0].(string); !strings.Contains(d, "NOT_SERVING") {
+		t.Fatalf("detail %q does not carry the proto payload", d)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestPanicLogHasPeerAndUserAgent(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	client := startBufconn(t, panicHealth{}, grpc.ChainUnaryInterceptor(UnaryPanicInterceptor()))
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("code %s, want Internal", status.Code(err))
+	}
+	entries := logs.WithMessage("panic in gRPC handler")
+	if len(entries) != 1 {
+		t.Fatalf("%d panic entries, want 1", len(entries))
+	}
+	if entries[0]["peer"] != "bufconn" {
+		t.Fatalf("peer %v, want bufconn", entries[0]["peer"])
+	}
+	if ua, _ := entries[0]["user_agent"].(string); !strings.HasPrefix(ua, "audit-client") {
+		t.Fatalf("user_agent %q, want audit-client prefix", ua)
+	}
+}
+
+func TestPeerInfoWithoutPeer(t *testing.T) {
+	addr, ua := peerInfo(context.Background())
+	if addr != "unknown" || ua != "unknown" {
+		t.Fatalf("peerInfo = %q, %q; want unknown, unknown", addr, ua)
+	}
+}
+
+func TestPanicLogFieldsAreSeparateInCloudMode(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	client := startBufconn(t, panicHealth{}, grpc.ChainUnaryInterceptor(UnaryPanicInterceptor()))
+	_, _ = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+	entries := logs.WithMessage("panic in gRPC handler")
+	if len(entries) != 1 {
+		t.Fatalf("%d panic entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e["method"] != "/grpc.health.v1.Health/Check" {
+		t.Fatalf("method %v", e["method"])
+	}
+	if e["panic_value"] != "boom" {
+		t.Fatalf("panic_value %v, want boom", e["panic_value"])
+	}
+	if stack, _ := e["stack"].(string); !strings.Contains(stack, "panicHealth.Check") {
+		t.Fatalf("stack field does not hold the handler stack: %q", stack)
+	}
+	if _, ok := e["args"]; ok {
+		t.Fatalf("stack leaked into args: %v", e["args"])
+	}
+}
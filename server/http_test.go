@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+// getWhenUp повторяет GET, пока сервер не начнёт принимать соединения
+func getWhenUp(t *testing.T, url string) string {
+	t.Helper()
+	var (
+		resp *http.Response
+		err  error
+	)
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = http.Get(url); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// stopServer останавливает сервер и ждёт выхода его горутины, чтобы её логи не пережили тест
+func stopServer(t *testing.T, shutdown func(), base int64) {
+	t.Helper()
+	shutdown()
+	deadline := time.Now().Add(time.Second)
+	for utils.ActiveGoRecoverCount() > base {
+		if time.Now().After(deadline) {
+			t.Fatal("server goroutine did not exit")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type appKey struct{}
+
+func TestBaseContextVisibleInHandler(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		v, _ := r.Context().Value(appKey{}).(string)
+		_, _ = io.WriteString(w, v)
+	}
+	baseContext := func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), appKey{}, "app-scoped")
+	}
+
+	servers := map[string]func(addr string) func(){
+		"chi": func(addr string) func() {
+			return CreateHTTPChiServer(func(s *HTTPServerChi) {
+				s.BaseContext = baseContext
+				s.Router.Get("/", handler)
+			}, addr)
+		},
+		"gorilla": func(addr string) func() {
+			return CreateHttpServer(func(s *HTTPServer) {
+				s.BaseContext = baseContext
+				s.Router.HandleFunc("/", handler)
+			}, addr)
+		},
+	}
+	for name, start := range servers {
+		t.Run(name, func(t *testing.T) {
+			base := utils.ActiveGoRecoverCount()
+			addr := freeAddr(t)
+			shutdown := start(addr)
+			defer stopServer(t, shutdown, base)
+
+			if got := getWhenUp(t, "http://"+addr+"/"); got != "app-scoped" {
+				t.Fatalf("handler saw %q, want the BaseContext value", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/locker"
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyLockTTL срок блокировки выполняющегося запроса; пока обработчик работает, она продлевается
+	DefaultIdempotencyLockTTL = 30 * time.Second
+	// DefaultIdempotencyMaxWait сколько конкурентный дубликат ждёт первый запрос, прежде чем получить 409
+	DefaultIdempotencyMaxWait = 10 * time.Second
+
+	idempotencyKeyPrefix    = "idempotency:"
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// IdempotencyStore хранилище закэшированных ответов для IdempotencyMiddleware.
+type IdempotencyStore interface {
+	// Get возвращает сохранённый ответ, ok == false если его нет или он истёк
+	Get(ctx context.Context, key string) (data []byte, ok bool, err error)
+	Set(ctx context.Context, key string, data []byte, ttl time.Duration) error
+}
+
+type IdempotencyOption func(*idempotencyConfig)
+
+type idempotencyConfig struct {
+	codec   locker.Codec
+	lockTTL time.Duration
+	maxWait time.Duration
+}
+
+// WithIdempotencyCodec формат сохранённых ответов, по умолчанию locker.JSONCodec.
+func WithIdempotencyCodec(codec locker.Codec) IdempotencyOption {
+	return func(cfg *idempotencyConfig) {
+		cfg.codec = codec
+	}
+}
+
+// WithIdempotencyLockTTL срок блокировки на время выполнения запроса, по умолчанию DefaultIdempotencyLockTTL.
+// Блокировка продлевается каждую треть срока, так что если процесс умер посреди обработчика, ключ освободится
+// через lockTTL, а не через ttl кэша ответов.
+func WithIdempotencyLockTTL(lockTTL time.Duration) IdempotencyOption {
+	return func(cfg *idempotencyConfig) {
+		cfg.lockTTL = lockTTL
+	}
+}
+
+// WithIdempotencyMaxWait сколько дубликат ждёт ответ первого запроса, по умолчанию DefaultIdempotencyMaxWait.
+// После этого он получает 409, даже если его собственный контекст ещё жив.
+func WithIdempotencyMaxWait(maxWait time.Duration) IdempotencyOption {
+	return func(cfg *idempotencyConfig) {
+		cfg.maxWait = maxWait
+	}
+}
+
+type idempotentResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// IdempotencyMiddleware повторный запрос с тем же Idempotency-Key (и тем же методом и путём) в течение ttl
+// получает сохранённый ответ вместо повторного выполнения. Конкурентный дубликат ждёт, пока первый
+// запрос закончится, и получает его ответ; ждёт не дольше WithIdempotencyMaxWait, затем 409.
+// Выполняющийся запрос держит отдельную короткую блокировку (WithIdempotencyLockTTL), а не ttl.
+// Ответы 5xx не кэшируются, чтобы клиент мог повторить запрос.
+// Запросы без заголовка проходят как обычно.
+func IdempotencyMiddleware(lck locker.Locker, store IdempotencyStore, ttl time.Duration, opts ...IdempotencyOption) func(http.Handler) http.Handler {
+	cfg := &idempotencyConfig{codec: locker.JSONCodec{}, lockTTL: DefaultIdempotencyLockTTL, maxWait: DefaultIdempotencyMaxWait}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.lockTTL <= 0 {
+		cfg.lockTTL = DefaultIdempotencyLockTTL
+	}
+	if cfg.maxWait <= 0 {
+		cfg.maxWait = DefaultIdempotencyMaxWait
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			key = fmt.Sprintf("%s%s %s %s", idempotencyKeyPrefix, r.Method, r.URL.Path, key)
+
+			owner := uuid.NewString()
+			waitLimit := time.NewTimer(cfg.maxWait)
+			defer waitLimit.Stop()
+			var guard *locker.LockGuard
+			for {
+				if replayed, err := replayIdempotent(ctx, w, store, cfg.codec, key); err != nil || replayed {
+					if err != nil {
+						idempotencyFailed(ctx, w, err)
+					}
+					return
+				}
+
+				var err error
+				guard, err = locker.AcquireGuard(ctx, lck, key, owner, cfg.lockTTL, cfg.lockTTL/3)
+				if err == nil {
+					break
+				}
+				if !errors.Is(err, locker.ErrLockNotAcquired) {
+					idempotencyFailed(ctx, w, err)
+					return
+				}
+
+				// ключ держит конкурентный дубликат — ждём его ответ
+				select {
+				case <-ctx.Done():
+				case <-waitLimit.C:
+				case <-time.After(idempotencyPollInterval):
+					continue
+				}
+				http.Error(w, "request with the same Idempotency-Key is in progress", http.StatusConflict)
+				return
+			}
+			defer func() {
+				_ = guard.Release(context.WithoutCancel(ctx))
+			}()
+
+			// ответ мог сохраниться между проверкой и взятием блокировки
+			if replayed, err := replayIdempotent(ctx, w, store, cfg.codec, key); err != nil || replayed {
+				if err != nil {
+					idempotencyFailed(ctx, w, err)
+				}
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status >= http.StatusInternalServerError {
+				return
+			}
+
+			data, err := cfg.codec.Marshal(idempotentResponse{Status: rec.status, Header: w.Header().Clone(), Body: rec.body.Bytes()})
+			if err == nil {
+				err = store.Set(context.WithoutCancel(ctx), key, data, ttl)
+			}
+			if err != nil {
+				logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
+					Msg:       "Failed to save idempotent response",
+					Component: "HTTPServer",
+					Method:    "IdempotencyMiddleware",
+					Args:      key,
+					Error:     err,
+				})
+			}
+		})
+	}
+}
+
+func replayIdempotent(ctx context.Context, w http.ResponseWriter, store IdempotencyStore, codec locker.Codec, key string) (bool, error) {
+	data, ok, err := store.Get(ctx, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	var resp idempotentResponse
+	if err := codec.Unmarshal(data, &resp); err != nil {
+		return false, err
+	}
+
+	// уже выставленные заголовки (например X-Correlation-ID текущего запроса) не перетираем
+	for k, v := range resp.Header {
+		if _, set := w.Header()[k]; !set {
+			w.Header()[k] = v
+		}
+	}
+	w.Header().Set(IdempotencyReplayedHeader, "true")
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+	return true, nil
+}
+
+func idempotencyFailed(ctx context.Context, w http.ResponseWriter, err error) {
+	logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
+		Msg:       "Idempotency check failed",
+		Component: "HTTPServer",
+		Method:    "IdempotencyMiddleware",
+		Error:     err,
+	})
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// idempotencyRecorder пропускает ответ клиенту и параллельно копит его для кэша.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+type RedisIdempotencyStore struct {
+	client *redis.Client
+}
+
+func NewRedisIdempotencyStore(client *redis.Client) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client}
+}
+
+func (s *RedisIdempotencyStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get: %v", err)
+	}
+	return data, true, nil
+}
+
+func (s *RedisIdempotencyStore) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if err := s.client.Set(ctx, key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("set: %v", err)
+	}
+	return nil
+}
+
+// MemoryIdempotencyStore in-memory IdempotencyStore для тестов и одного инстанса.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+}
+
+type memoryIdempotencyEntry struct {
+	data     []byte
+	expireAt time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]memoryIdempotencyEntry)}
+}
+
+func (s *MemoryIdempotencyStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !time.Now().Before(e.expireAt) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	return e.data, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Set(_ context.Context, key string, data []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = memoryIdempotencyEntry{data: data, expireAt: time.Now().Add(ttl)}
+	return nil
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/locker"
+)
+
+// countingHandler отвечает 201 с номером выполнения
+func countingHandler(calls *atomic.Int64, delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		time.Sleep(delay)
+		w.Header().Set("X-Order", fmt.Sprint(n))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, "order %d", n)
+	})
+}
+
+func postWithKey(h http.Handler, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysDuplicate(t *testing.T) {
+	var calls atomic.Int64
+	h := IdempotencyMiddleware(locker.NewMemoryLocker(), NewMemoryIdempotencyStore(), time.Minute)(countingHandler(&calls, 0))
+
+	first := postWithKey(h, "k1")
+	second := postWithKey(h, "k1")
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls.Load())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != "order 1" || second.Header().Get("X-Order") != "1" {
+		t.Fatalf("replay %d %q, want the first response", second.Code, second.Body.String())
+	}
+	if first.Header().Get(IdempotencyReplayedHeader) != "" || second.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Fatal("replayed header is set on the wrong response")
+	}
+
+	if postWithKey(h, "k2").Body.String() != "order 2" || postWithKey(h, "").Body.String() != "order 3" {
+		t.Fatal("other keys and requests without a key must execute")
+	}
+}
+
+func TestIdempotencyConcurrentDuplicateWaits(t *testing.T) {
+	var calls atomic.Int64
+	h := IdempotencyMiddleware(locker.NewMemoryLocker(), NewMemoryIdempotencyStore(), time.Minute)(countingHandler(&calls, 100*time.Millisecond))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 4)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[i] = postWithKey(h, "same").Body.String()
+		}()
+	}
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times for concurrent duplicates, want 1", calls.Load())
+	}
+	for _, b := range bodies {
+		if b != "order 1" {
+			t.Fatalf("bodies %q, want all order 1", bodies)
+		}
+	}
+}
+
+func TestIdempotencyDoesNotCacheServerErrors(t *testing.T) {
+	var calls atomic.Int64
+	h := IdempotencyMiddleware(locker.NewMemoryLocker(), NewMemoryIdempotencyStore(), time.Minute)(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+	postWithKey(h, "k")
+	postWithKey(h, "k")
+	if calls.Load() != 2 {
+		t.Fatalf("handler ran %d times, want a retry after 5xx", calls.Load())
+	}
+}
+
+// ttlLocker запоминает сроки, с которыми берётся и продлевается блокировка
+type ttlLocker struct {
+	locker.Locker
+	mu      sync.Mutex
+	lockTTL []time.Duration
+	extends int
+}
+
+func (l *ttlLocker) Lock(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	l.mu.Lock()
+	l.lockTTL = append(l.lockTTL, expiration)
+	l.mu.Unlock()
+	return l.Locker.Lock(ctx, key, value, expiration)
+}
+
+func (l *ttlLocker) ExtendLockTTL(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	l.mu.Lock()
+	l.extends++
+	l.mu.Unlock()
+	return l.Locker.ExtendLockTTL(ctx, key, value, expiration)
+}
+
+func TestIdempotencyLockIsShortAndRenewedWhileHandlerRuns(t *testing.T) {
+	var calls atomic.Int64
+	lck := &ttlLocker{Locker: locker.NewMemoryLocker()}
+	h := IdempotencyMiddleware(lck, NewMemoryIdempotencyStore(), time.Hour, WithIdempotencyLockTTL(90*time.Millisecond))(
+		countingHandler(&calls, 300*time.Millisecond))
+
+	first := make(chan string, 1)
+	go func() { first <- postWithKey(h, "slow").Body.String() }()
+	// дубликат приходит, когда исходный срок блокировки уже истёк бы без продления
+	time.Sleep(150 * time.Millisecond)
+	second := postWithKey(h, "slow")
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler ran %d times, want 1: the lock expired while the handler was running", calls.Load())
+	}
+	if b := <-first; b != "order 1" || second.Body.String() != "order 1" || second.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Fatalf("first %q, duplicate %d %q, want a replay of order 1", b, second.Code, second.Body.String())
+	}
+	lck.mu.Lock()
+	defer lck.mu.Unlock()
+	if len(lck.lockTTL) == 0 || lck.lockTTL[0] != 90*time.Millisecond {
+		t.Fatalf("lock taken with %v, want the lock TTL rather than the cache ttl", lck.lockTTL)
+	}
+	if lck.extends < 2 {
+		t.Fatalf("lock extended %d times during a 300ms handler, want at least 2", lck.extends)
+	}
+}
+
+func TestIdempotencyWaiterGivesUpAfterMaxWait(t *testing.T) {
+	var calls atomic.Int64
+	lck := locker.NewMemoryLocker()
+	// ключ держит зависший запрос другого инстанса
+	if ok, err := lck.Lock(context.Background(), "idempotency:POST /orders stuck", "other", time.Minute); !ok || err != nil {
+		t.Fatalf("lock: %v %v", ok, err)
+	}
+	h := IdempotencyMiddleware(lck, NewMemoryIdempotencyStore(), time.Hour, WithIdempotencyMaxWait(100*time.Millisecond))(
+		countingHandler(&calls, 0))
+
+	start := time.Now()
+	rec := postWithKey(h, "stuck")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("waiter polled for %s with a 100ms max wait", elapsed)
+	}
+	if rec.Code != http.StatusConflict || calls.Load() != 0 {
+		t.Fatalf("status %d after %d handler calls, want 409 without executing", rec.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyKeyFreedAfterHolderDied(t *testing.T) {
+	var calls atomic.Int64
+	lck := locker.NewMemoryLocker()
+	// процесс умер посреди обработчика: его блокировка никем не продлевается и истекает по lock TTL
+	if ok, err := lck.Lock(context.Background(), "idempotency:POST /orders orphan", "dead", 100*time.Millisecond); !ok || err != nil {
+		t.Fatalf("lock: %v %v", ok, err)
+	}
+	h := IdempotencyMiddleware(lck, NewMemoryIdempotencyStore(), time.Hour)(countingHandler(&calls, 0))
+
+	if rec := postWithKey(h, "orphan"); rec.Code != http.StatusCreated || calls.Load() != 1 {
+		t.Fatalf("status %d after %d handler calls, want the retry to execute once the lock expired", rec.Code, calls.Load())
+	}
+}
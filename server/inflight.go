@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"github.com/PavelAgarkov/service-pkg/utils"
+)
+
+var drainProgressInterval = time.Second
+
+// InFlightCounter считает запросы, которые сейчас обрабатываются.
+// Серверы пакета оборачивают им обработчик сами, чтобы показывать прогресс при остановке.
+type InFlightCounter struct {
+	n atomic.Int64
+}
+
+func NewInFlightCounter() *InFlightCounter {
+	return &InFlightCounter{}
+}
+
+func (c *InFlightCounter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.n.Add(1)
+		defer c.n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *InFlightCounter) Count() int64 {
+	return c.n.Load()
+}
+
+// logDrainProgress пока идёт srv.Shutdown, раз в drainProgressInterval пишет, сколько запросов ещё в работе.
+// Возвращает функцию, которую нужно вызвать после завершения Shutdown.
+func logDrainProgress(ctx context.Context, counter *InFlightCounter, port string) func() {
+	done := make(chan struct{})
+	utils.GoRecover(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(drainProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n := counter.Count(); n > 0 {
+					logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+						Msg:       fmt.Sprintf("draining: %d requests remaining", n),
+						Component: "HTTPServer",
+						Method:    "shutdown",
+						Args:      port,
+					})
+				}
+			}
+		}
+	})
+
+	return func() {
+		close(done)
+		if n := counter.Count(); n > 0 {
+			logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("HTTP drain finished with %d requests still in flight", n),
+				Component: "HTTPServer",
+				Method:    "shutdown",
+				Args:      port,
+			})
+		}
+	}
+}
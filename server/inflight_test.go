@@ -0,0 +1,82 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+func TestInFlightCounter(t *testing.T) {
+	c := NewInFlightCounter()
+	inside := make(chan int64, 1)
+	h := c.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { inside <- c.Count() }))
+
+	h.ServeHTTP(nil, nil)
+	if n := <-inside; n != 1 {
+		t.Fatalf("count inside handler %d, want 1", n)
+	}
+	if n := c.Count(); n != 0 {
+		t.Fatalf("count after handler %d, want 0", n)
+	}
+}
+
+func TestShutdownLogsDrainProgress(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	prev := drainProgressInterval
+	drainProgressInterval = 20 * time.Millisecond
+	t.Cleanup(func() { drainProgressInterval = prev })
+
+	addr := freeAddr(t)
+	started := make(chan struct{})
+	s := newHTTPServer(addr)
+	s.Router.Get("/slow", func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		time.Sleep(150 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})
+	shutdown := s.run(nil)
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		var (
+			resp *http.Response
+			err  error
+		)
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if resp, err = http.Get("http://" + addr + "/slow"); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{string(body), err}
+	}()
+
+	<-started
+	shutdown()
+
+	r := <-results
+	if r.err != nil || r.body != "done" {
+		t.Fatalf("in-flight request: body %q, err %v; want it completed", r.body, r.err)
+	}
+	if len(logs.WithMessage("draining: 1 requests remaining")) == 0 {
+		t.Fatal("no drain progress logged")
+	}
+	for _, e := range logs.Entries() {
+		if e["level"] == "warn" || e["level"] == "error" {
+			t.Fatalf("unclean drain: %v", e)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unknownRoute метка route для запросов, не попавших ни в один маршрут (404): сырой путь дал бы неограниченную кардинальность
+const unknownRoute = "unknown"
+
+// HTTPMetrics RED-метрики HTTP-серверов: число запросов и гистограмма длительности по method/route/code
+// и число запросов в работе по method. route — шаблон маршрута (/users/{id}), а не путь.
+type HTTPMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// NewHTTPMetrics регистрирует метрики http_requests_total, http_request_duration_seconds и http_requests_in_flight в reg.
+func NewHTTPMetrics(reg prometheus.Registerer) (*HTTPMetrics, error) {
+	m := &HTTPMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of handled HTTP requests.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being handled.",
+		}, []string{"method"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.duration, m.inFlight} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ChiMiddleware для chi: шаблон маршрута берётся из chi.RouteContext после обработки запроса.
+func (m *HTTPMetrics) ChiMiddleware(next http.Handler) http.Handler {
+	return m.middleware(next, func(r *http.Request) string {
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			return rctx.RoutePattern()
+		}
+		return ""
+	})
+}
+
+// GorillaMiddleware для gorilla/mux, подключать через Router.Use, чтобы маршрут уже был найден.
+func (m *HTTPMetrics) GorillaMiddleware(next http.Handler) http.Handler {
+	return m.middleware(next, func(r *http.Request) string {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				return tpl
+			}
+		}
+		return ""
+	})
+}
+
+func (m *HTTPMetrics) middleware(next http.Handler, route func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight := m.inFlight.WithLabelValues(r.Method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		lrw := newStatusRecorder(w)
+		start := time.Now()
+		next.ServeHTTP(lrw, r)
+
+		pattern := route(r)
+		if pattern == "" {
+			pattern = unknownRoute
+		}
+		code := strconv.Itoa(lrw.statusCode)
+		m.requests.WithLabelValues(r.Method, pattern, code).Inc()
+		m.duration.WithLabelValues(r.Method, pattern, code).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+const DebugTraceHeader = "X-Debug-Trace"
+
+// DebugTraceMiddleware включает debug-логи для запросов с заголовком X-Debug-Trace,
+// остальные запросы логируются по текущему уровню. Подходит и для chi, и для gorilla.
+// Заголовок может прислать любой клиент, поэтому на публичном контуре его стоит вырезать на балансере.
+func DebugTraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(DebugTraceHeader) != "" {
+			r = r.WithContext(logger.WithDebugTrace(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Ниже общие реализации мидлвар chi и gorilla серверов: публичные RecoverChiMiddleware/RecoverMiddleware,
+// LoggingChiMiddleware/LoggingMiddleware и т.д. — тонкие обёртки над ними.
+
+func loggerContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+	})
+}
+
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				logger.WriteErrorLog(r.Context(), &logger_wrapper.LogEntry{
+					Msg:       "panic caught in HTTP request",
+					Error:     fmt.Errorf("%v", rec),
+					Component: "HTTPServer",
+					Method:    "RecoverMiddleware",
+					Args:      fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+				})
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		corrID := requestCorrelationID(r)
+		ctx := WithCorrelationID(r.Context(), corrID)
+
+		w.Header().Set(CorrelationIDHeader, corrID)
+
+		lrw := newStatusRecorder(w)
+
+		start := time.Now()
+		next.ServeHTTP(lrw, r.WithContext(ctx))
+
+		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       fmt.Sprintf("%s %s completed", r.Method, r.URL.Path),
+			Component: "HTTPServer",
+			Method:    "LoggingMiddleware",
+			Args: fmt.Sprintf("status=%d duration=%s ua=%s",
+				lrw.statusCode, time.Since(start), r.UserAgent()),
+		})
+	})
+}
+
+// statusRecorder запоминает код ответа для логов и метрик.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{w, http.StatusOK}
+}
+
+func (lrw *statusRecorder) WriteHeader(code int) {
+	lrw.statusCode = code
+	lrw.ResponseWriter.WriteHeader(code)
+}
+
+func (lrw *statusRecorder) Write(b []byte) (int, error) {
+	return lrw.ResponseWriter.Write(b)
+}
+
+func (lrw *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("ResponseWriter does not implement http.Hijacker")
+	}
+	return hj.Hijack()
+}
+
+// Unwrap для http.ResponseController (Flush, SetWriteDeadline и т.п.)
+func (lrw *statusRecorder) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
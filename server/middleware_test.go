@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDebugTraceHeaderEnablesDebugLogs(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger, logtest.WithLevel(zapcore.InfoLevel))
+	handler := DebugTraceMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.WriteDebugLog(r.Context(), &logger_wrapper.LogEntry{Msg: "handler debug", Args: r.URL.Path})
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/plain", nil))
+	traced := httptest.NewRequest(http.MethodGet, "/traced", nil)
+	traced.Header.Set(DebugTraceHeader, "1")
+	handler.ServeHTTP(httptest.NewRecorder(), traced)
+
+	entries := logs.WithMessage("handler debug")
+	if len(entries) != 1 || entries[0]["args"] != "/traced" {
+		t.Fatalf("debug entries %v, want only the traced request", entries)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// CreateGRPCHTTPServer поднимает gRPC и HTTP (chi) на одном порту configs.Port.
+// Разделение по h2c: HTTP/2 запросы с content-type application/grpc уходят в grpc.Server.ServeHTTP,
+// всё остальное (HTTP/1.1 и обычный HTTP/2) — в chi роутер.
+// configs.Health и configs.HealthBarrier работают так же, как в CreateGRPCServer.
+// Возвращает общую функцию остановки: сначала health в NOT_SERVING, затем graceful HTTP, затем gRPC.
+// gRPC на общем порту работает через ServeHTTP, у которого нет GracefulStop (grpc-go паникует на Drain),
+// поэтому активные gRPC стримы на этом порту закрываются жёстко через Stop.
+func CreateGRPCHTTPServer(
+	ctx context.Context,
+	registerServices func(*grpc.Server),
+	routes func(*HTTPServerChi),
+	configs Configs,
+	serverOptions ...grpc.ServerOption,
+) func() {
+	grpcServer := grpc.NewServer(serverOptions...)
+	registerServices(grpcServer)
+	if configs.Reflection {
+		reflection.Register(grpcServer)
+	}
+	stopHealth := configs.registerHealth(ctx, grpcServer)
+
+	s := newHTTPServer(configs.Port)
+	s.apply(routes)
+
+	httpShutdown := s.run(h2c.NewHandler(splitGRPC(grpcServer, s.Router), &http2.Server{}))
+
+	return func() {
+		// пробы видят NOT_SERVING ещё во время graceful остановки HTTP
+		stopHealth()
+		httpShutdown()
+		grpcServer.Stop()
+		logger.WriteInfoLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       "gRPC over shared HTTP port has stopped",
+			Component: "GRPCHTTPServer",
+			Method:    "shutdown",
+			Args:      configs.Port,
+		})
+	}
+}
+
+func splitGRPC(grpcServer *grpc.Server, httpHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+	return addr
+}
+
+func TestGRPCAndHTTPShareOnePort(t *testing.T) {
+	addr := freeAddr(t)
+	shutdown := CreateGRPCHTTPServer(context.Background(),
+		func(s *grpc.Server) { healthpb.RegisterHealthServer(s, health.NewServer()) },
+		func(s *HTTPServerChi) {
+			s.Router.Get("/ping", func(w http.ResponseWriter, _ *http.Request) { _, _ = io.WriteString(w, "pong") })
+		},
+		Configs{Port: addr, Network: "tcp"},
+	)
+	defer shutdown()
+
+	var body []byte
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		resp, err := http.Get("http://" + addr + "/ping")
+		if err == nil {
+			body, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("HTTP server did not come up: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if string(body) != "pong" {
+		t.Fatalf("HTTP body %q, want pong", body)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("gRPC call on the shared port: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health status %s, want SERVING", resp.GetStatus())
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// TLSConfig настройки HTTPS для HTTPServerChi и HTTPServer. Если в Config заданы Certificates
+// или GetCertificate, CertFile и KeyFile можно не указывать.
+type TLSConfig struct {
+	Config   *tls.Config
+	CertFile string
+	KeyFile  string
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.Config != nil || c.CertFile != "" || c.KeyFile != ""
+}
+
+func (c TLSConfig) listenAndServe(srv *http.Server) error {
+	if !c.enabled() {
+		return srv.ListenAndServe()
+	}
+	return srv.ListenAndServeTLS(c.CertFile, c.KeyFile)
+}
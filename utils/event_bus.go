@@ -0,0 +1,93 @@
+package utils
+
+import "sync"
+
+type EventBusConfig struct {
+	// Buffer размер буфера канала каждого подписчика, по умолчанию 16
+	Buffer int
+	// DropSlowSubscribers отписывает (и закрывает канал) подписчика, чей буфер переполнен.
+	// Без флага событие для такого подписчика просто теряется.
+	DropSlowSubscribers bool
+}
+
+// EventBus внутрипроцессный pub/sub. Publish никогда не блокируется на медленных подписчиках.
+type EventBus[T any] struct {
+	mu     sync.Mutex
+	cfg    EventBusConfig
+	subs   map[uint64]chan T
+	nextID uint64
+	closed bool
+}
+
+func NewEventBus[T any](cfg EventBusConfig) *EventBus[T] {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 16
+	}
+	return &EventBus[T]{
+		cfg:  cfg,
+		subs: make(map[uint64]chan T),
+	}
+}
+
+// Subscribe возвращает канал событий и функцию отписки. Канал закрывается при отписке,
+// при Close шины или при отбрасывании медленного подписчика. Подписка на закрытую шину
+// возвращает сразу закрытый канал.
+func (b *EventBus[T]) Subscribe() (<-chan T, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan T, b.cfg.Buffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = ch
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(id)
+	}
+}
+
+func (b *EventBus[T]) Publish(event T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	for id, ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			if b.cfg.DropSlowSubscribers {
+				b.remove(id)
+			}
+		}
+	}
+}
+
+// Close закрывает каналы всех подписчиков, последующие Publish игнорируются.
+func (b *EventBus[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for id := range b.subs {
+		b.remove(id)
+	}
+}
+
+// remove вызывать под b.mu
+func (b *EventBus[T]) remove(id uint64) {
+	if ch, ok := b.subs[id]; ok {
+		delete(b.subs, id)
+		close(ch)
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEventBusDeliversToAllSubscribers(t *testing.T) {
+	bus := NewEventBus[int](EventBusConfig{})
+	a, _ := bus.Subscribe()
+	b, _ := bus.Subscribe()
+
+	bus.Publish(1)
+	bus.Publish(2)
+	for name, ch := range map[string]<-chan int{"a": a, "b": b} {
+		if got := []int{<-ch, <-ch}; got[0] != 1 || got[1] != 2 {
+			t.Fatalf("subscriber %s got %v, want [1 2]", name, got)
+		}
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	bus := NewEventBus[int](EventBusConfig{})
+	ch, unsubscribe := bus.Subscribe()
+	other, _ := bus.Subscribe()
+
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(1)
+
+	if _, ok := <-ch; ok {
+		t.Fatal("unsubscribed channel received an event")
+	}
+	if v := <-other; v != 1 {
+		t.Fatalf("remaining subscriber got %d, want 1", v)
+	}
+}
+
+func TestEventBusSlowSubscriber(t *testing.T) {
+	for _, drop := range []bool{false, true} {
+		bus := NewEventBus[int](EventBusConfig{Buffer: 1, DropSlowSubscribers: drop})
+		slow, _ := bus.Subscribe()
+		fast, _ := bus.Subscribe()
+
+		bus.Publish(1)
+		<-fast
+		// буфер slow заполнен: Publish не должен блокироваться
+		bus.Publish(2)
+		if v := <-fast; v != 2 {
+			t.Fatalf("drop=%v: fast subscriber got %d, want 2", drop, v)
+		}
+
+		if v := <-slow; v != 1 {
+			t.Fatalf("drop=%v: slow subscriber got %d, want 1", drop, v)
+		}
+		bus.Publish(3)
+		v, ok := <-slow
+		if drop && ok {
+			t.Fatalf("dropped subscriber still receives events: %d", v)
+		}
+		if !drop && (!ok || v != 3) {
+			t.Fatalf("kept subscriber got %d (open %v), want 3", v, ok)
+		}
+	}
+}
+
+func TestEventBusClose(t *testing.T) {
+	bus := NewEventBus[int](EventBusConfig{})
+	ch, unsubscribe := bus.Subscribe()
+	bus.Close()
+	bus.Publish(1)
+	unsubscribe()
+
+	if _, ok := <-ch; ok {
+		t.Fatal("channel open after Close")
+	}
+	late, _ := bus.Subscribe()
+	if _, ok := <-late; ok {
+		t.Fatal("subscription after Close is open")
+	}
+}
+
+func TestEventBusConcurrentUse(t *testing.T) {
+	bus := NewEventBus[int](EventBusConfig{DropSlowSubscribers: true})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bus.Publish(j)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			ch, unsubscribe := bus.Subscribe()
+			defer unsubscribe()
+			for j := 0; j < 10; j++ {
+				select {
+				case <-ch:
+				default:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	bus.Close()
+}
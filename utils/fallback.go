@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+// WithFallback пробует получить значение из fn не дольше d, иначе возвращает fallback.
+// Для необязательного обогащения ответа («имя пользователя, если успеем»). Ошибка или паника fn тоже дают fallback.
+// fn получает контекст с таймаутом; если fn его не слушает, WithFallback всё равно вернётся через d,
+// а fn доработает в фоне.
+func WithFallback[T any](ctx context.Context, d time.Duration, fn func(ctx context.Context) (T, error), fallback T) T {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: PanicError(r)}
+			}
+		}()
+		value, err := fn(ctx)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return fallback
+		}
+		return r.value
+	case <-ctx.Done():
+		msg := "timed out, using fallback"
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// отменили вызывающий контекст — это не таймаут fn
+			msg = "context cancelled, using fallback"
+		}
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       msg,
+			Component: "utils",
+			Method:    "WithFallback",
+			Args:      fmt.Sprintf("timeout: %s", d),
+			Error:     ctx.Err(),
+		})
+		return fallback
+	}
+}
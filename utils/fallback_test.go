@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+func TestWithFallbackSuccess(t *testing.T) {
+	got := WithFallback(context.Background(), time.Second, func(context.Context) (string, error) {
+		return "value", nil
+	}, "fallback")
+	if got != "value" {
+		t.Fatalf("got %q, want value", got)
+	}
+}
+
+func TestWithFallbackError(t *testing.T) {
+	got := WithFallback(context.Background(), time.Second, func(context.Context) (string, error) {
+		return "partial", errors.New("boom")
+	}, "fallback")
+	if got != "fallback" {
+		t.Fatalf("got %q, want fallback", got)
+	}
+}
+
+func TestWithFallbackPanic(t *testing.T) {
+	got := WithFallback(context.Background(), time.Second, func(context.Context) (string, error) {
+		panic("boom")
+	}, "fallback")
+	if got != "fallback" {
+		t.Fatalf("got %q, want fallback", got)
+	}
+}
+
+func TestWithFallbackTimeout(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	release := make(chan struct{})
+	defer close(release)
+
+	start := time.Now()
+	got := WithFallback(context.Background(), 20*time.Millisecond, func(context.Context) (string, error) {
+		<-release // fn не слушает ctx
+		return "late", nil
+	}, "fallback")
+	if got != "fallback" {
+		t.Fatalf("got %q, want fallback", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %s, want about 20ms", elapsed)
+	}
+	if len(logs.WithMessage("timed out, using fallback")) != 1 {
+		t.Fatalf("timeout is not logged: %v", logs.Entries())
+	}
+}
+
+func TestWithFallbackParentCancelIsNotTimeout(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	got := WithFallback(ctx, time.Second, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, "fallback")
+	if got != "fallback" {
+		t.Fatalf("got %q, want fallback", got)
+	}
+	if len(logs.WithMessage("timed out, using fallback")) != 0 {
+		t.Fatal("cancellation is logged as a timeout")
+	}
+}
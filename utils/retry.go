@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Multiplier float64
+	// Retryable решает, стоит ли повторять попытку после ошибки. nil — повторяем любую ошибку.
+	Retryable func(err error) bool
+}
+
+// RetryAfterError ошибка, которая сама знает, сколько ждать до следующей попытки (например, HTTP Retry-After).
+// Если RetryAfter() > 0, Retry ждёт столько вместо своей паузы.
+type RetryAfterError interface {
+	error
+	RetryAfter() time.Duration
+}
+
+// RetriesExhausted возвращается Retry, когда попытки закончились, а fn так и не отработала успешно.
+type RetriesExhausted struct {
+	Attempts int
+	Elapsed  time.Duration
+	Last     error
+}
+
+func (e *RetriesExhausted) Error() string {
+	return fmt.Sprintf("retries exhausted after %d attempts in %s: %v", e.Attempts, e.Elapsed, e.Last)
+}
+
+func (e *RetriesExhausted) Unwrap() error {
+	return e.Last
+}
+
+func (p RetryPolicy) normalize() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = 1
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+	return p
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		d = time.Duration(float64(d) * p.Multiplier)
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// wait пауза после неудачной попытки attempt: RetryAfter из ошибки, если он есть, иначе backoff.
+func (p RetryPolicy) wait(attempt int, err error) time.Duration {
+	var ra RetryAfterError
+	if errors.As(err, &ra) {
+		if d := ra.RetryAfter(); d > 0 {
+			return d
+		}
+	}
+	return p.backoff(attempt)
+}
+
+// Retry вызывает fn до policy.Attempts раз с паузами между попытками.
+// Если попытки исчерпаны, возвращает *RetriesExhausted, ошибка контекста возвращается как есть.
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	policy = policy.normalize()
+	start := time.Now()
+
+	var last error
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		last = fn(ctx)
+		if last == nil {
+			return nil
+		}
+		if policy.Retryable != nil && !policy.Retryable(last) {
+			return last
+		}
+		if attempt == policy.Attempts {
+			break
+		}
+
+		if err := WaitOrCtx(ctx, policy.wait(attempt, last)); err != nil {
+			return err
+		}
+	}
+
+	return &RetriesExhausted{
+		Attempts: policy.Attempts,
+		Elapsed:  time.Since(start),
+		Last:     last,
+	}
+}
+
+// ErrRetryBudgetExhausted до дедлайна ctx не помещается следующая попытка вместе с паузой перед ней.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryWithBudget как Retry, но учитывает дедлайн ctx: перед каждой попыткой проверяет, что пауза
+// и minAttempt ещё помещаются в оставшееся время, иначе сразу возвращает ошибку с ErrRetryBudgetExhausted
+// (и последней ошибкой fn, если она была). Каждая попытка получает долю оставшегося времени:
+// остаток делится поровну между оставшимися попытками, но не меньше minAttempt.
+// Без дедлайна в ctx ведёт себя как Retry.
+func RetryWithBudget(ctx context.Context, policy RetryPolicy, minAttempt time.Duration, fn func(ctx context.Context) error) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return Retry(ctx, policy, fn)
+	}
+	policy = policy.normalize()
+	start := time.Now()
+
+	var last error
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var wait time.Duration
+		if attempt > 1 {
+			wait = policy.wait(attempt-1, last)
+		}
+		remaining := time.Until(deadline)
+		if wait+minAttempt > remaining {
+			if last == nil {
+				return fmt.Errorf("%w: %s left, attempt needs %s", ErrRetryBudgetExhausted, remaining, minAttempt)
+			}
+			return fmt.Errorf("%w after %d attempts (%s left): %w", ErrRetryBudgetExhausted, attempt-1, remaining, last)
+		}
+		if err := WaitOrCtx(ctx, wait); err != nil {
+			return err
+		}
+
+		share := max(time.Until(deadline)/time.Duration(policy.Attempts-attempt+1), minAttempt)
+		attemptCtx, cancel := context.WithTimeout(ctx, share)
+		last = fn(attemptCtx)
+		cancel()
+		if last == nil {
+			return nil
+		}
+		if policy.Retryable != nil && !policy.Retryable(last) {
+			return last
+		}
+	}
+
+	return &RetriesExhausted{
+		Attempts: policy.Attempts,
+		Elapsed:  time.Since(start),
+		Last:     last,
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryExhaustedWrapsLastError(t *testing.T) {
+	underlying := errors.New("db is down")
+	calls := 0
+	err := Retry(context.Background(), RetryPolicy{Attempts: 3, Backoff: time.Millisecond}, func(context.Context) error {
+		calls++
+		return underlying
+	})
+
+	if !errors.Is(err, underlying) {
+		t.Fatalf("errors.Is(%v, underlying) = false", err)
+	}
+	var exhausted *RetriesExhausted
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("got %T, want *RetriesExhausted", err)
+	}
+	if exhausted.Attempts != 3 || calls != 3 {
+		t.Fatalf("Attempts %d, calls %d, want 3", exhausted.Attempts, calls)
+	}
+	if exhausted.Last != underlying {
+		t.Fatalf("Last = %v, want underlying", exhausted.Last)
+	}
+	if exhausted.Elapsed < 2*time.Millisecond {
+		t.Fatalf("Elapsed %s does not include backoff", exhausted.Elapsed)
+	}
+}
+
+func TestRetrySucceedsWithoutExhausted(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), RetryPolicy{Attempts: 3}, func(context.Context) error {
+		calls++
+		if calls < 2 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("err %v, calls %d", err, calls)
+	}
+}
+
+func TestRetryNonRetryableReturnedAsIs(t *testing.T) {
+	fatal := errors.New("fatal")
+	err := Retry(context.Background(), RetryPolicy{Attempts: 3, Retryable: func(err error) bool { return false }}, func(context.Context) error {
+		return fatal
+	})
+	if err != fatal {
+		t.Fatalf("got %v, want the fn error as is", err)
+	}
+}
+
+func TestRetryWithBudgetStartsNoAttemptWhenBudgetExhausted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := RetryWithBudget(ctx, RetryPolicy{Attempts: 3}, 100*time.Millisecond, func(context.Context) error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, ErrRetryBudgetExhausted) {
+		t.Fatalf("err %v, want ErrRetryBudgetExhausted", err)
+	}
+	if calls != 0 {
+		t.Fatalf("%d attempts started without budget", calls)
+	}
+}
+
+func TestRetryWithBudgetStopsBeforeAttemptThatCannotFit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	underlying := errors.New("db is down")
+	calls := 0
+	start := time.Now()
+	// после первой попытки пауза 300ms уже не помещается в оставшиеся ~200ms
+	err := RetryWithBudget(ctx, RetryPolicy{Attempts: 3, Backoff: 300 * time.Millisecond}, 10*time.Millisecond, func(context.Context) error {
+		calls++
+		return underlying
+	})
+	if !errors.Is(err, ErrRetryBudgetExhausted) || !errors.Is(err, underlying) {
+		t.Fatalf("err %v, want ErrRetryBudgetExhausted wrapping the last error", err)
+	}
+	if calls != 1 {
+		t.Fatalf("%d attempts, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("returned after %s, want to abort without waiting for the backoff", elapsed)
+	}
+}
+
+func TestRetryWithBudgetSharesRemainingTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	var shares []time.Duration
+	err := RetryWithBudget(ctx, RetryPolicy{Attempts: 3}, time.Millisecond, func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		shares = append(shares, time.Until(deadline))
+		if len(shares) < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// первая попытка получает примерно треть бюджета, а не весь
+	if shares[0] > 110*time.Millisecond || shares[0] < 50*time.Millisecond {
+		t.Fatalf("first attempt share %s, want about 100ms", shares[0])
+	}
+}
+
+func TestRetryWithBudgetWithoutDeadlineActsAsRetry(t *testing.T) {
+	calls := 0
+	err := RetryWithBudget(context.Background(), RetryPolicy{Attempts: 2}, time.Hour, func(context.Context) error {
+		calls++
+		return errors.New("transient")
+	})
+	var exhausted *RetriesExhausted
+	if !errors.As(err, &exhausted) || calls != 2 {
+		t.Fatalf("err %v, calls %d, want RetriesExhausted after 2", err, calls)
+	}
+}
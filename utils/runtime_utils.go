@@ -2,21 +2,76 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
 	"time"
 
 	"github.com/PavelAgarkov/service-pkg/logger"
 	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
 )
 
+var (
+	activeGoRecover    atomic.Int64
+	goRecoverSoftLimit atomic.Int64
+	panicObserver      atomic.Pointer[func(value any, stack []byte)]
+)
+
+// SetPanicObserver задаёт наблюдателя за паниками, пойманными GoRecover и Recover (например, отправка в error tracking).
+// Вызывается до записи в лог, со значением паники и стеком. nil отключает наблюдателя.
+func SetPanicObserver(observer func(value any, stack []byte)) {
+	if observer == nil {
+		panicObserver.Store(nil)
+		return
+	}
+	panicObserver.Store(&observer)
+}
+
+// NotifyPanic передаёт панику наблюдателю из SetPanicObserver, если он задан.
+// Для собственных recover-обработчиков, чтобы паники доходили до того же наблюдателя.
+func NotifyPanic(value any, stack []byte) {
+	if observer := panicObserver.Load(); observer != nil {
+		(*observer)(value, stack)
+	}
+}
+
+// ActiveGoRecoverCount число горутин, запущенных через GoRecover и ещё не завершившихся.
+func ActiveGoRecoverCount() int64 {
+	return activeGoRecover.Load()
+}
+
+// SetGoRecoverSoftLimit при превышении limit активных горутин GoRecover пишет warning (только в момент превышения).
+// Горутины не ограничиваются, это сигнал утечки. limit <= 0 отключает проверку.
+func SetGoRecoverSoftLimit(limit int64) {
+	goRecoverSoftLimit.Store(limit)
+}
+
+// GoRecover запускает fn в горутине с recover. ctx передаётся в fn как есть, поэтому его значения
+// (в том числе поля логгера из zap_engine.WithContextField, например correlation_id) доступны внутри fn
+// и попадают во внутренние логи GoRecover: панику и отмену до старта.
 func GoRecover(ctx context.Context, fn func(ctx context.Context)) {
+	active := activeGoRecover.Add(1)
+	if limit := goRecoverSoftLimit.Load(); limit > 0 && active == limit+1 {
+		logger.WriteWarnLog(ctx, &logger_wrapper.LogEntry{
+			Msg:       "active GoRecover goroutines exceeded soft limit",
+			Component: "utils",
+			Method:    "GoRecover",
+			Args:      fmt.Sprintf("active: %d, limit: %d", active, limit),
+		})
+	}
+
 	go func() {
+		defer activeGoRecover.Add(-1)
 		defer func() {
 			if r := recover(); r != nil {
+				stack := debug.Stack()
+				NotifyPanic(r, stack)
 				logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
 					Msg:       "recovered from panic in goroutine",
-					Error:     r.(error),
+					Error:     PanicError(r),
 					Component: "utils",
 					Method:    "GoRecover",
+					Args:      string(stack),
 				})
 			}
 		}()
@@ -36,15 +91,26 @@ func GoRecover(ctx context.Context, fn func(ctx context.Context)) {
 
 func Recover(ctx context.Context) {
 	if r := recover(); r != nil {
+		stack := debug.Stack()
+		NotifyPanic(r, stack)
 		logger.WriteErrorLog(ctx, &logger_wrapper.LogEntry{
 			Msg:       "recovered from panic in goroutine",
-			Error:     r.(error),
+			Error:     PanicError(r),
 			Component: "utils",
 			Method:    "Recover",
+			Args:      string(stack),
 		})
 	}
 }
 
+// PanicError приводит значение паники к error: panic("boom") или panic(42) не обязаны быть ошибками.
+func PanicError(r any) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
+
 func WaitOrCtx(ctx context.Context, wait time.Duration) error {
 	select {
 	case <-ctx.Done():
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/internal/logtest"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
+)
+
+// waitEntry ждёт появления записи с сообщением msg
+func waitEntry(t *testing.T, logs *logtest.Buffer, msg string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if entries := logs.WithMessage(msg); len(entries) > 0 {
+			return entries[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no %q log entry", msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitActive ждёт, пока число активных горутин GoRecover не станет n
+func waitActive(t *testing.T, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for ActiveGoRecoverCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("ActiveGoRecoverCount %d, want %d", ActiveGoRecoverCount(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGoRecoverPanicLogHasCorrelationID(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	base := ActiveGoRecoverCount()
+	ctx := logger.WithContextField(context.Background(), "correlation_id", "req-42")
+
+	GoRecover(ctx, func(context.Context) { panic("boom") })
+
+	e := waitEntry(t, logs, "recovered from panic in goroutine")
+	waitActive(t, base)
+	if e["correlation_id"] != "req-42" {
+		t.Fatalf("correlation_id %v, want req-42", e["correlation_id"])
+	}
+}
+
+func TestGoRecoverCancelledLogHasCorrelationID(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	base := ActiveGoRecoverCount()
+	ctx, cancel := context.WithCancel(logger.WithContextField(context.Background(), "correlation_id", "req-43"))
+	cancel()
+
+	GoRecover(ctx, func(context.Context) { t.Error("fn ran on a cancelled context") })
+
+	e := waitEntry(t, logs, "goroutine cancelled before start")
+	waitActive(t, base)
+	if e["correlation_id"] != "req-43" {
+		t.Fatalf("correlation_id %v, want req-43", e["correlation_id"])
+	}
+}
+
+func TestActiveGoRecoverCount(t *testing.T) {
+	base := ActiveGoRecoverCount()
+	release := make(chan struct{})
+	const n = 10
+	for i := 0; i < n; i++ {
+		GoRecover(context.Background(), func(context.Context) { <-release })
+	}
+	if got := ActiveGoRecoverCount(); got != base+n {
+		t.Fatalf("ActiveGoRecoverCount %d, want %d", got, base+n)
+	}
+	close(release)
+	waitActive(t, base)
+}
+
+func TestGoRecoverSoftLimitWarnsOnce(t *testing.T) {
+	logs := logtest.Capture(t, logger.InitLogger)
+	base := ActiveGoRecoverCount()
+	SetGoRecoverSoftLimit(base + 2)
+	defer SetGoRecoverSoftLimit(0)
+
+	release := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		GoRecover(context.Background(), func(context.Context) { <-release })
+	}
+	close(release)
+	waitActive(t, base)
+
+	if n := len(logs.WithMessage("active GoRecover goroutines exceeded soft limit")); n != 1 {
+		t.Fatalf("%d soft limit warnings, want 1", n)
+	}
+}
+
+func TestPanicObserverReceivesPanicsFromRecoverAndGoRecover(t *testing.T) {
+	logtest.Capture(t, logger.InitLogger)
+	base := ActiveGoRecoverCount()
+	observed := make(chan any, 2)
+	SetPanicObserver(func(value any, stack []byte) {
+		if len(stack) == 0 {
+			t.Error("observer got an empty stack")
+		}
+		observed <- value
+	})
+	t.Cleanup(func() { SetPanicObserver(nil) })
+
+	func() {
+		defer Recover(context.Background())
+		panic("from Recover")
+	}()
+	GoRecover(context.Background(), func(context.Context) { panic("from GoRecover") })
+	waitActive(t, base)
+
+	got := make([]any, 0, 2)
+	for len(got) < 2 {
+		select {
+		case v := <-observed:
+			got = append(got, v)
+		case <-time.After(time.Second):
+			t.Fatalf("observer got %v, want panics from both paths", got)
+		}
+	}
+	if got[0] != "from Recover" || got[1] != "from GoRecover" {
+		t.Fatalf("observer got %v", got)
+	}
+}
+
+func TestRecoverLogsNonErrorPanicValues(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"string", "boom", "boom"},
+		{"int", 42, "42"},
+		{"error", errors.New("closed pipe"), "closed pipe"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs := logtest.Capture(t, logger.InitLogger)
+			base := ActiveGoRecoverCount()
+
+			GoRecover(context.Background(), func(context.Context) { panic(tc.value) })
+			waitActive(t, base)
+			func() {
+				defer Recover(context.Background())
+				panic(tc.value)
+			}()
+
+			entries := logs.WithMessage("recovered from panic in goroutine")
+			if len(entries) != 2 {
+				t.Fatalf("%d panic entries, want 2", len(entries))
+			}
+			for _, e := range entries {
+				if e["error"] != tc.want {
+					t.Errorf("%s: error %v, want %q", e["method"], e["error"], tc.want)
+				}
+				if args, _ := e["args"].(string); !strings.Contains(args, "goroutine ") || !strings.Contains(args, "runtime_utils_test.go") {
+					t.Errorf("%s: args do not carry the stack: %q", e["method"], args)
+				}
+			}
+		})
+	}
+}
+
+func TestPanicError(t *testing.T) {
+	err := errors.New("original")
+	if PanicError(err) != err {
+		t.Fatal("error panic value was wrapped")
+	}
+	if got := PanicError(3.5).Error(); got != "3.5" {
+		t.Fatalf("PanicError(3.5) = %q", got)
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+)
+
+// cacheLinePad размер кэш-линии, чтобы соседние шарды не делили одну линию (false sharing)
+const cacheLinePad = 64
+
+type counterShard struct {
+	n atomic.Int64
+	_ [cacheLinePad - 8]byte
+}
+
+// StripedCounter счётчик без общей точки contention: инкременты размазываются по шардам,
+// а Sum собирает их вместе. Sum не является атомарным снимком при параллельных Inc.
+type StripedCounter struct {
+	shards []counterShard
+	mask   uint32
+}
+
+// NewStripedCounter shards округляется вверх до степени двойки, при shards <= 0 берём GOMAXPROCS.
+func NewStripedCounter(shards int) *StripedCounter {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	size := 1
+	for size < shards {
+		size <<= 1
+	}
+	return &StripedCounter{
+		shards: make([]counterShard, size),
+		mask:   uint32(size - 1),
+	}
+}
+
+func (c *StripedCounter) Inc() {
+	c.Add(1)
+}
+
+func (c *StripedCounter) Add(delta int64) {
+	c.shards[rand.Uint32()&c.mask].n.Add(delta)
+}
+
+func (c *StripedCounter) Sum() int64 {
+	var sum int64
+	for i := range c.shards {
+		sum += c.shards[i].n.Load()
+	}
+	return sum
+}
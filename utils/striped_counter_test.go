@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStripedCounterSum(t *testing.T) {
+	c := NewStripedCounter(0)
+	const goroutines, perGoroutine = 16, 10000
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	c.Add(-5)
+
+	if got, want := c.Sum(), int64(goroutines*perGoroutine-5); got != want {
+		t.Fatalf("Sum() = %d, want %d", got, want)
+	}
+}
+
+func TestNewStripedCounterRoundsToPowerOfTwo(t *testing.T) {
+	if n := len(NewStripedCounter(5).shards); n != 8 {
+		t.Fatalf("got %d shards, want 8", n)
+	}
+}
+
+func BenchmarkStripedCounter(b *testing.B) {
+	c := NewStripedCounter(0)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.Inc()
+		}
+	})
+}
+
+func BenchmarkSingleAtomic(b *testing.B) {
+	var n atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n.Add(1)
+		}
+	})
+}
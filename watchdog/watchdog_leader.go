@@ -2,10 +2,16 @@ package watchdog
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/PavelAgarkov/service-pkg/locker"
+	"github.com/PavelAgarkov/service-pkg/logger"
+	logger "github.com/PavelAgarkov/service-pkg/logger/zap_engine"
 	"github.com/PavelAgarkov/service-pkg/utils"
 	"github.com/google/uuid"
 )
@@ -19,26 +25,70 @@ const (
 
 	LostAcquire  = 1
 	TakenAcquire = 2
+	// GaveUp терминальное событие: превышен MaxConsecutiveLockFailures, после него watcher закрывается.
+	GaveUp = 3
 )
 
+var processStartedAt = time.Now()
+
 type Config struct {
 	ElectionName string
 	Expiration   time.Duration
+	// MaxConsecutiveLockFailures сколько ошибок локера подряд (Lock/ExtendLockTTL вернули error) допускается,
+	// прежде чем сдаться с событием GaveUp. 0 — пытаться бесконечно.
+	MaxConsecutiveLockFailures int
+	// IdentityProvider значение блокировки, по которому видно, какой инстанс держит лидерство (например HostIdentity).
+	// Должно быть уникальным для каждого Elect, иначе два участника сочтут блокировку своей. nil — uuid.
+	IdentityProvider func() string
+}
+
+// HostIdentity значение блокировки вида "hostname/pid/время старта/случайный суффикс".
+func HostIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s/%d/%s/%s", hostname, os.Getpid(), processStartedAt.Format(time.RFC3339), uuid.NewString()[:8])
 }
 
 type RedisWatchdogLeader struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	locker locker.Locker
+	logger logger_wrapper.Logger
+	wg     sync.WaitGroup // горутины выборов, StopAndWait ждёт их выхода
+
+	mu        sync.Mutex
+	elections []*election
+}
+
+// election состояние одних выборов для IsLeader
+type election struct {
+	name   string
+	leader atomic.Bool
+}
+
+type Option func(*RedisWatchdogLeader)
+
+// WithLogger логгер для внутренних событий выборов (ошибки локера, смена лидерства), по умолчанию глобальный zap_engine.
+func WithLogger(l logger_wrapper.Logger) Option {
+	return func(rwl *RedisWatchdogLeader) {
+		rwl.logger = l
+	}
 }
 
-func NewRedisWatchdogLeader(ctx context.Context, locker locker.Locker) *RedisWatchdogLeader {
+func NewRedisWatchdogLeader(ctx context.Context, locker locker.Locker, opts ...Option) *RedisWatchdogLeader {
 	ctx, cancel := context.WithCancel(ctx)
-	return &RedisWatchdogLeader{
+	rwl := &RedisWatchdogLeader{
 		ctx:    ctx,
 		cancel: cancel,
 		locker: locker,
+		logger: logger.Global(),
+	}
+	for _, opt := range opts {
+		opt(rwl)
 	}
+	return rwl
 }
 
 func (rwl *RedisWatchdogLeader) Elect(cfg Config) <-chan int {
@@ -51,15 +101,29 @@ func (rwl *RedisWatchdogLeader) Elect(cfg Config) <-chan int {
 
 	watcher := make(chan int, 8) // 8 на случай моргания сети или редиса, чтобы не блокировать поток сразу
 
-	utils.GoRecover(rwl.ctx, func(ctx context.Context) {
+	state := rwl.addElection(cfg.ElectionName)
+
+	rwl.wg.Add(1)
+	// WithoutCancel: горутина должна стартовать всегда, иначе wg.Done не вызовется и StopAndWait зависнет
+	utils.GoRecover(context.WithoutCancel(rwl.ctx), func(context.Context) {
+		defer rwl.wg.Done()
 		defer close(watcher)
+		defer rwl.removeElection(state)
+		ctx := rwl.ctx
 
 		value := uuid.NewString()
+		if cfg.IdentityProvider != nil {
+			value = cfg.IdentityProvider()
+		}
 		renewIntervalJitter := cfg.Expiration/3 + time.Duration(rand.Int63n(int64(cfg.Expiration/10)))
 		ticker := time.NewTicker(renewIntervalJitter)
 		defer ticker.Stop()
 
 		isLeader := false
+		setLeader := func(leader bool) {
+			isLeader = leader
+			state.leader.Store(leader)
+		}
 		send := func(event int) {
 			select {
 			case <-ctx.Done():
@@ -68,11 +132,68 @@ func (rwl *RedisWatchdogLeader) Elect(cfg Config) <-chan int {
 			}
 		}
 
+		failures := 0
+		// gaveUp учитывает результат операции локера и сообщает, пора ли сдаваться
+		gaveUp := func(err error) bool {
+			if err == nil {
+				failures = 0
+				return false
+			}
+			failures++
+			rwl.logger.Warn(ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Election %s lock operation failed (%d in a row)", cfg.ElectionName, failures),
+				Component: "watchdog",
+				Method:    "Elect",
+				Args:      value,
+				Error:     err,
+			})
+			if cfg.MaxConsecutiveLockFailures <= 0 || failures < cfg.MaxConsecutiveLockFailures {
+				return false
+			}
+			if isLeader {
+				setLeader(false)
+				send(LostAcquire)
+			}
+			rwl.logger.Error(ctx, &logger_wrapper.LogEntry{
+				Msg:       fmt.Sprintf("Election %s gave up after %d lock failures", cfg.ElectionName, failures),
+				Component: "watchdog",
+				Method:    "Elect",
+				Args:      value,
+				Error:     err,
+			})
+			send(GaveUp)
+			return true
+		}
+
+		// acquire одним атомарным вызовом берёт или продлевает блокировку и переключает состояние
+		acquire := func() bool {
+			ok, err := rwl.locker.AcquireOrExtend(ctx, cfg.ElectionName, value, cfg.Expiration)
+			switch {
+			case ok && !isLeader:
+				setLeader(true)
+				rwl.logger.Info(ctx, &logger_wrapper.LogEntry{
+					Msg:       fmt.Sprintf("Election %s leadership taken", cfg.ElectionName),
+					Component: "watchdog",
+					Method:    "Elect",
+					Args:      value,
+				})
+				send(TakenAcquire)
+			case !ok && isLeader:
+				setLeader(false)
+				rwl.logger.Warn(ctx, &logger_wrapper.LogEntry{
+					Msg:       fmt.Sprintf("Election %s leadership lost", cfg.ElectionName),
+					Component: "watchdog",
+					Method:    "Elect",
+					Args:      value,
+				})
+				send(LostAcquire)
+			}
+			return !gaveUp(err)
+		}
+
 		// для выбора лидера сразу
-		ok, _ := rwl.locker.Lock(ctx, cfg.ElectionName, value, cfg.Expiration)
-		if ok {
-			isLeader = true
-			send(TakenAcquire)
+		if !acquire() {
+			return
 		}
 
 		for {
@@ -80,24 +201,13 @@ func (rwl *RedisWatchdogLeader) Elect(cfg Config) <-chan int {
 			case <-ctx.Done():
 				if isLeader {
 					_, _ = rwl.locker.Unlock(context.Background(), cfg.ElectionName, value)
-					isLeader = false
+					setLeader(false)
 					send(LostAcquire)
-					return
 				}
+				return
 			case <-ticker.C:
-				if !isLeader {
-					ok, _ := rwl.locker.Lock(ctx, cfg.ElectionName, value, cfg.Expiration)
-					if ok {
-						isLeader = true
-						send(TakenAcquire)
-					}
-					continue
-				}
-
-				ok, _ := rwl.locker.ExtendLockTTL(ctx, cfg.ElectionName, value, cfg.Expiration)
-				if !ok {
-					isLeader = false
-					send(LostAcquire)
+				if !acquire() {
+					return
 				}
 			}
 		}
@@ -106,8 +216,74 @@ func (rwl *RedisWatchdogLeader) Elect(cfg Config) <-chan int {
 	return watcher
 }
 
+// addElection регистрирует состояние новых выборов. Прежние выборы с тем же именем (повторный Elect после
+// закрытия watcher) заменяются, чтобы IsLeader и Stop не обходили мёртвые записи.
+func (rwl *RedisWatchdogLeader) addElection(name string) *election {
+	state := &election{name: name}
+	rwl.mu.Lock()
+	defer rwl.mu.Unlock()
+	for i, e := range rwl.elections {
+		if e.name == name {
+			rwl.elections[i] = state
+			return state
+		}
+	}
+	rwl.elections = append(rwl.elections, state)
+	return state
+}
+
+// removeElection убирает выборы, горутина которых завершилась (если их ещё не заменили новыми).
+func (rwl *RedisWatchdogLeader) removeElection(state *election) {
+	rwl.mu.Lock()
+	defer rwl.mu.Unlock()
+	for i, e := range rwl.elections {
+		if e == state {
+			rwl.elections = append(rwl.elections[:i], rwl.elections[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stop отменяет все выборы; IsLeader сразу становится false, не дожидаясь Unlock в горутинах.
 func (rwl *RedisWatchdogLeader) Stop() {
 	if rwl.cancel != nil {
 		rwl.cancel()
 	}
+	rwl.mu.Lock()
+	for _, e := range rwl.elections {
+		e.leader.Store(false)
+	}
+	rwl.mu.Unlock()
+}
+
+// IsLeader держим ли мы сейчас лидерство хотя бы в одних выборах этого RedisWatchdogLeader.
+// Обновляется горутиной выборов на каждом TakenAcquire/LostAcquire.
+func (rwl *RedisWatchdogLeader) IsLeader() bool {
+	rwl.mu.Lock()
+	defer rwl.mu.Unlock()
+	for _, e := range rwl.elections {
+		if e.leader.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLeaderOf то же для выборов с конкретным ElectionName.
+func (rwl *RedisWatchdogLeader) IsLeaderOf(electionName string) bool {
+	rwl.mu.Lock()
+	defer rwl.mu.Unlock()
+	for _, e := range rwl.elections {
+		if e.name == electionName && e.leader.Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// StopAndWait как Stop, но возвращается только после выхода всех горутин выборов, включая финальный Unlock.
+// Вызывать перед закрытием Redis-клиента, иначе Unlock может прийтись на закрытое соединение.
+func (rwl *RedisWatchdogLeader) StopAndWait() {
+	rwl.Stop()
+	rwl.wg.Wait()
 }
@@ -0,0 +1,290 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/service-pkg/locker"
+	"github.com/PavelAgarkov/service-pkg/logger"
+)
+
+// failingLocker локер, у которого не проходит ни одна операция
+type failingLocker struct {
+	locker.Locker
+}
+
+func (failingLocker) AcquireOrExtend(context.Context, string, string, time.Duration) (bool, error) {
+	return false, errors.New("redis is down")
+}
+
+func waitEvent(t *testing.T, watcher <-chan int, want int) {
+	t.Helper()
+	select {
+	case got := <-watcher:
+		if got != want {
+			t.Fatalf("event %d, want %d", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no event %d", want)
+	}
+}
+
+func waitClosed(t *testing.T, watcher <-chan int) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-watcher:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("watcher is not closed")
+		}
+	}
+}
+
+func (rwl *RedisWatchdogLeader) electionCount() int {
+	rwl.mu.Lock()
+	defer rwl.mu.Unlock()
+	return len(rwl.elections)
+}
+
+func TestElectTakesLeadership(t *testing.T) {
+	rwl := NewRedisWatchdogLeader(context.Background(), locker.NewMemoryLocker())
+	defer rwl.StopAndWait()
+
+	watcher := rwl.Elect(Config{ElectionName: "test", Expiration: time.Second})
+	waitEvent(t, watcher, TakenAcquire)
+	if !rwl.IsLeader() || !rwl.IsLeaderOf("test") {
+		t.Fatal("IsLeader is false after TakenAcquire")
+	}
+}
+
+func TestReElectDoesNotKeepDeadElections(t *testing.T) {
+	rwl := NewRedisWatchdogLeader(context.Background(), failingLocker{})
+	defer rwl.StopAndWait()
+
+	cfg := Config{ElectionName: "test", Expiration: time.Second, MaxConsecutiveLockFailures: 1}
+	for i := 0; i < 5; i++ {
+		watcher := rwl.Elect(cfg)
+		waitEvent(t, watcher, GaveUp)
+		waitClosed(t, watcher)
+	}
+	rwl.wg.Wait()
+	if n := rwl.electionCount(); n != 0 {
+		t.Fatalf("%d elections kept after their goroutines exited", n)
+	}
+}
+
+func TestElectReplacesElectionWithSameName(t *testing.T) {
+	rwl := NewRedisWatchdogLeader(context.Background(), locker.NewMemoryLocker())
+	defer rwl.StopAndWait()
+
+	rwl.addElection("test")
+	rwl.addElection("test")
+	rwl.addElection("other")
+	if n := rwl.electionCount(); n != 2 {
+		t.Fatalf("got %d elections, want 2", n)
+	}
+}
+
+// flakyLocker успешно отвечает на первые ok вызовов AcquireOrExtend, дальше только ошибки
+type flakyLocker struct {
+	locker.Locker
+	ok    int64
+	calls atomic.Int64
+}
+
+func (l *flakyLocker) AcquireOrExtend(context.Context, string, string, time.Duration) (bool, error) {
+	if l.calls.Add(1) <= l.ok {
+		return true, nil
+	}
+	return false, errors.New("redis is down")
+}
+
+func TestElectGivesUpAfterMaxFailures(t *testing.T) {
+	l := &flakyLocker{}
+	rwl := NewRedisWatchdogLeader(context.Background(), l)
+	defer rwl.StopAndWait()
+
+	watcher := rwl.Elect(Config{ElectionName: "test", Expiration: 30 * time.Millisecond, MaxConsecutiveLockFailures: 3})
+	waitEvent(t, watcher, GaveUp)
+	waitClosed(t, watcher)
+	if n := l.calls.Load(); n != 3 {
+		t.Fatalf("%d lock attempts before giving up, want 3", n)
+	}
+}
+
+func TestLeaderGivesUpWithLostAcquireFirst(t *testing.T) {
+	l := &flakyLocker{ok: 1}
+	rwl := NewRedisWatchdogLeader(context.Background(), l)
+	defer rwl.StopAndWait()
+
+	watcher := rwl.Elect(Config{ElectionName: "test", Expiration: 30 * time.Millisecond, MaxConsecutiveLockFailures: 2})
+	waitEvent(t, watcher, TakenAcquire)
+	waitEvent(t, watcher, LostAcquire)
+	waitEvent(t, watcher, GaveUp)
+	waitClosed(t, watcher)
+	if rwl.IsLeader() {
+		t.Fatal("still leader after giving up")
+	}
+}
+
+// recordingLocker запоминает значения, с которыми берётся блокировка
+type recordingLocker struct {
+	*locker.MemoryLocker
+	mu     sync.Mutex
+	values []string
+}
+
+func (l *recordingLocker) AcquireOrExtend(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	l.values = append(l.values, value)
+	l.mu.Unlock()
+	return l.MemoryLocker.AcquireOrExtend(ctx, key, value, ttl)
+}
+
+func TestElectStoresCustomIdentity(t *testing.T) {
+	mem := locker.NewMemoryLocker()
+	rec := &recordingLocker{MemoryLocker: mem}
+	rwl := NewRedisWatchdogLeader(context.Background(), rec)
+	defer rwl.StopAndWait()
+
+	watcher := rwl.Elect(Config{ElectionName: "test", Expiration: time.Second, IdentityProvider: func() string { return "node-a/42" }})
+	waitEvent(t, watcher, TakenAcquire)
+
+	rec.mu.Lock()
+	values := append([]string(nil), rec.values...)
+	rec.mu.Unlock()
+	if len(values) == 0 || values[0] != "node-a/42" {
+		t.Fatalf("lock values %v, want node-a/42", values)
+	}
+	// блокировка принадлежит именно этому значению: чужое её не получит, а своё продлит
+	if ok, err := mem.AcquireOrExtend(context.Background(), "test", "someone-else", time.Second); err != nil || ok {
+		t.Fatalf("foreign value took the lock: ok=%v err=%v", ok, err)
+	}
+	if ok, err := mem.AcquireOrExtend(context.Background(), "test", "node-a/42", time.Second); err != nil || !ok {
+		t.Fatalf("custom identity does not own the lock: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestHostIdentityIsUniquePerCall(t *testing.T) {
+	a, b := HostIdentity(), HostIdentity()
+	if a == b {
+		t.Fatalf("HostIdentity returned %q twice", a)
+	}
+	if parts := strings.Split(a, "/"); len(parts) != 4 || parts[1] != strconv.Itoa(os.Getpid()) {
+		t.Fatalf("HostIdentity %q, want hostname/pid/started/suffix", a)
+	}
+}
+
+// recordedEntry запись recordingLogger с уровнем
+type recordedEntry struct {
+	level string
+	entry logger_wrapper.LogEntry
+}
+
+// recordingLogger logger_wrapper.Logger, складывающий записи в память
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []recordedEntry
+}
+
+func (l *recordingLogger) add(level string, e *logger_wrapper.LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, recordedEntry{level: level, entry: *e})
+}
+
+func (l *recordingLogger) Info(_ context.Context, e *logger_wrapper.LogEntry)  { l.add("info", e) }
+func (l *recordingLogger) Warn(_ context.Context, e *logger_wrapper.LogEntry)  { l.add("warn", e) }
+func (l *recordingLogger) Error(_ context.Context, e *logger_wrapper.LogEntry) { l.add("error", e) }
+
+func TestInjectedLoggerCapturesLockFailures(t *testing.T) {
+	logs := &recordingLogger{}
+	rwl := NewRedisWatchdogLeader(context.Background(), failingLocker{}, WithLogger(logs))
+	defer rwl.StopAndWait()
+
+	watcher := rwl.Elect(Config{
+		ElectionName:               "test",
+		Expiration:                 30 * time.Millisecond,
+		MaxConsecutiveLockFailures: 2,
+		IdentityProvider:           func() string { return "node-a" },
+	})
+	waitEvent(t, watcher, GaveUp)
+	waitClosed(t, watcher)
+
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	want := []recordedEntry{
+		{level: "warn", entry: logger_wrapper.LogEntry{Msg: "Election test lock operation failed (1 in a row)"}},
+		{level: "warn", entry: logger_wrapper.LogEntry{Msg: "Election test lock operation failed (2 in a row)"}},
+		{level: "error", entry: logger_wrapper.LogEntry{Msg: "Election test gave up after 2 lock failures"}},
+	}
+	if len(logs.entries) != len(want) {
+		t.Fatalf("%d entries, want %d: %+v", len(logs.entries), len(want), logs.entries)
+	}
+	for i, got := range logs.entries {
+		if got.level != want[i].level || got.entry.Msg != want[i].entry.Msg {
+			t.Fatalf("entry %d: %s %q, want %s %q", i, got.level, got.entry.Msg, want[i].level, want[i].entry.Msg)
+		}
+		if got.entry.Component != "watchdog" || got.entry.Args != "node-a" || got.entry.Error == nil {
+			t.Fatalf("entry %d: %+v, want watchdog component, identity and error", i, got.entry)
+		}
+	}
+}
+
+// slowUnlockLocker отпускает блокировку с задержкой, как Redis на остановке
+type slowUnlockLocker struct {
+	*locker.MemoryLocker
+	// unlocked обычный bool: под -race чтение без happens-before от StopAndWait даст гонку
+	unlocked bool
+}
+
+func (l *slowUnlockLocker) Unlock(ctx context.Context, key, value string) (bool, error) {
+	time.Sleep(50 * time.Millisecond)
+	ok, err := l.MemoryLocker.Unlock(ctx, key, value)
+	l.unlocked = true
+	return ok, err
+}
+
+func TestStopAndWaitReturnsAfterElectionGoroutineExits(t *testing.T) {
+	mem := locker.NewMemoryLocker()
+	slow := &slowUnlockLocker{MemoryLocker: mem}
+	rwl := NewRedisWatchdogLeader(context.Background(), slow)
+
+	watcher := rwl.Elect(Config{ElectionName: "test", Expiration: time.Second})
+	waitEvent(t, watcher, TakenAcquire)
+
+	rwl.StopAndWait()
+
+	if !slow.unlocked {
+		t.Fatal("StopAndWait returned before the final Unlock")
+	}
+	if n := rwl.electionCount(); n != 0 {
+		t.Fatalf("%d elections left after StopAndWait", n)
+	}
+	// горутина уже вышла, значит watcher закрыт без ожидания
+	for {
+		select {
+		case _, ok := <-watcher:
+			if ok {
+				continue
+			}
+		default:
+			t.Fatal("watcher still open after StopAndWait")
+		}
+		break
+	}
+	if ok, err := mem.AcquireOrExtend(context.Background(), "test", "next-leader", time.Second); err != nil || !ok {
+		t.Fatalf("lock not released: ok=%v err=%v", ok, err)
+	}
+}